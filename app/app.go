@@ -15,10 +15,17 @@ var Module = fx.Module(
 	"app base and gateways",
 	fx.Provide(
 		redis.New,
+		redis.NewElector,
 		cats.New,
 		slack.New,
+		postgres.NewQueryStats,
+		postgres.Open,
+		postgres.OpenReplicas,
 		postgres.New,
+		postgres.NewMigrator,
+		postgres.NewFeed,
 	),
+	fx.Invoke(postgres.MigrateOnStart),
 	logger.Module,
 	config.Module,
 )
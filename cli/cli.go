@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"go.uber.org/fx"
+
+	"fx-sample-app/config"
+	"fx-sample-app/gateway/postgres"
+	"fx-sample-app/logger"
+)
+
+// errUsage is returned for malformed command lines.
+var errUsage = errors.New("usage")
+
+// command is a one off subcommand run instead of the server.
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+// commands maps subcommand names to implementations.
+var commands = map[string]command{
+	"import": {
+		usage: "import [-upsert] [-format csv|ndjson] people|places <file|->",
+		run:   importRecords,
+	},
+	"export": {
+		usage: "export [-deleted] [-format csv|ndjson] people|places [file]",
+		run:   exportRecords,
+	},
+	"seed": {
+		usage: "seed [-reset] [-env name | set ...]",
+		run:   seed,
+	},
+	"migrate": {
+		usage: "migrate up | down [steps] | to <version> | status",
+		run:   migrate,
+	},
+}
+
+// Run executes the subcommand in args and returns the exit code.
+func Run(args []string) int {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage()
+		return 2
+	}
+
+	err := cmd.run(ctx, args[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "usage: fx-sample-app %s\n", cmd.usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+// usage lists every command.
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("usage: fx-sample-app [command]\n\nWithout a command the server runs. Commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+// start builds and starts an app providing the gateways commands use,
+// populating targets. The returned stop must be called when done.
+func start(ctx context.Context, targets ...interface{}) (func(), error) {
+	app := fx.New(
+		fx.NopLogger,
+		config.Module,
+		logger.Module,
+		fx.Provide(
+			postgres.NewQueryStats,
+			postgres.Open,
+			postgres.New,
+			postgres.NewMigrator,
+			postgres.NewSeeder,
+		),
+		fx.Populate(targets...),
+	)
+	err := app.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		app.Stop(context.Background())
+	}, nil
+}
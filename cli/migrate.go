@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+// migrate applies, rolls back or reports schema migrations.
+func migrate(ctx context.Context, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errUsage
+	}
+
+	var m *postgres.Migrator
+	stop, err := start(ctx, &m)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errUsage
+			}
+		}
+		return m.Down(ctx, steps)
+	case "to":
+		if len(args) != 2 {
+			return errUsage
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return errUsage
+		}
+		return m.To(ctx, version)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(statuses)
+		return nil
+	}
+
+	return errUsage
+}
+
+// printStatus writes a migration status table to stdout.
+func printStatus(statuses []postgres.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", ""
+		if s.Applied {
+			state = "applied"
+			appliedAt = s.AppliedAt.Local().Format(time.RFC3339)
+		}
+		switch {
+		case s.Missing:
+			state = "applied, file missing"
+		case s.Modified:
+			state = "applied, file modified"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+}
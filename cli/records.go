@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"fx-sample-app/gateway/postgres"
+	"fx-sample-app/records"
+)
+
+// importFuncs and exportFuncs map resource names to bulk operations.
+var (
+	importFuncs = map[string]func(
+		ctx context.Context,
+		db postgres.Gateway,
+		r io.Reader,
+		format records.Format,
+		upsert bool,
+	) (int64, error){
+		"people": records.ImportPeople,
+		"places": records.ImportPlaces,
+	}
+	exportFuncs = map[string]func(
+		ctx context.Context,
+		db postgres.Gateway,
+		w io.Writer,
+		format records.Format,
+	) error{
+		"people": records.ExportPeople,
+		"places": records.ExportPlaces,
+	}
+)
+
+// importRecords loads a CSV or NDJSON file, - for stdin.
+func importRecords(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	upsert := flags.Bool("upsert", false, "")
+	formatName := flags.String("format", "", "")
+	if flags.Parse(args) != nil || flags.NArg() != 2 {
+		return errUsage
+	}
+	fn, ok := importFuncs[flags.Arg(0)]
+	if !ok {
+		return errUsage
+	}
+	path := flags.Arg(1)
+	format, err := fileFormat(*formatName, path)
+	if err != nil {
+		return err
+	}
+
+	r := os.Stdin
+	if path != "-" {
+		r, err = os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+	}
+
+	var db postgres.Gateway
+	stop, err := start(ctx, &db)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	imported, err := fn(ctx, db, r, format, *upsert)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d %s\n", imported, flags.Arg(0))
+	return nil
+}
+
+// exportRecords writes a CSV or NDJSON file, stdout when no file is given.
+// Deleted rows are left out unless -deleted is set.
+func exportRecords(ctx context.Context, args []string) (err error) {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	formatName := flags.String("format", "", "")
+	deleted := flags.Bool("deleted", false, "")
+	if flags.Parse(args) != nil || flags.NArg() < 1 || flags.NArg() > 2 {
+		return errUsage
+	}
+	fn, ok := exportFuncs[flags.Arg(0)]
+	if !ok {
+		return errUsage
+	}
+	path := "-"
+	if flags.NArg() == 2 {
+		path = flags.Arg(1)
+	}
+	format, err := fileFormat(*formatName, path)
+	if err != nil {
+		return err
+	}
+
+	var db postgres.Gateway
+	stop, err := start(ctx, &db)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	if *deleted {
+		ctx = postgres.WithDeleted(ctx)
+	}
+	if path == "-" {
+		return fn(ctx, db, os.Stdout, format)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+		// Leave no partial file behind.
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	return fn(ctx, db, f, format)
+}
+
+// fileFormat returns the named format, or the one of path's extension.
+func fileFormat(name, path string) (records.Format, error) {
+	if name != "" {
+		return records.ParseFormat(name)
+	}
+	if path == "-" {
+		return 0, fmt.Errorf("-format is required for stdin and stdout")
+	}
+	return records.FormatOf(path)
+}
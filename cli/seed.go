@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"io"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+// seed loads fixture sets, those of the environment when none are named.
+func seed(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	env := flags.String("env", "", "")
+	reset := flags.Bool("reset", false, "")
+	if flags.Parse(args) != nil || (*env != "" && flags.NArg() > 0) {
+		return errUsage
+	}
+
+	var s *postgres.Seeder
+	stop, err := start(ctx, &s)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	sets := flags.Args()
+	if len(sets) == 0 {
+		sets, err = s.EnvSets(*env)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *reset {
+		return s.Reset(ctx, sets...)
+	}
+	return s.Seed(ctx, sets...)
+}
@@ -2,7 +2,9 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"go.uber.org/fx"
@@ -12,41 +14,81 @@ import (
 	"fx-sample-app/gateway/postgres"
 	"fx-sample-app/gateway/redis"
 	"fx-sample-app/gateway/slack"
+	"fx-sample-app/records"
 )
 
+// factsChannel carries every fetched cat fact across instances.
+const factsChannel = "cat:facts"
+
 // Controller .
 type Controller interface {
 	CatFact(ctx context.Context) (string, error)
+	WatchFacts(ctx context.Context, fn func(fact string) error) error
+
+	CreatePerson(ctx context.Context, p postgres.Person) (postgres.Person, error)
+	GetPerson(ctx context.Context, id string) (postgres.Person, error)
+	UpdatePerson(ctx context.Context, p postgres.Person, columns []string) (postgres.Person, error)
+	DeletePerson(ctx context.Context, id string) error
+	ListPeople(ctx context.Context, page postgres.Page) ([]postgres.Person, bool, error)
+	EstimatePeople(ctx context.Context) (int64, error)
+	ImportPeople(ctx context.Context, r io.Reader, format records.Format, upsert bool) (int64, error)
+	ExportPeople(ctx context.Context, w io.Writer, format records.Format) error
+
+	CreatePlace(ctx context.Context, p postgres.Place) (postgres.Place, error)
+	GetPlace(ctx context.Context, id string) (postgres.Place, error)
+	UpdatePlace(ctx context.Context, p postgres.Place, columns []string) (postgres.Place, error)
+	DeletePlace(ctx context.Context, id string) error
+	AddPlaceComment(ctx context.Context, placeID string, c postgres.Comment) (postgres.Comment, error)
+	RemovePlaceComment(ctx context.Context, placeID, commentID string) error
+	ListPlaces(ctx context.Context, page postgres.Page) ([]postgres.Place, bool, error)
+	EstimatePlaces(ctx context.Context) (int64, error)
+	ImportPlaces(ctx context.Context, r io.Reader, format records.Format, upsert bool) (int64, error)
+	ExportPlaces(ctx context.Context, w io.Writer, format records.Format) error
+
+	CreateRelationship(ctx context.Context, r postgres.Relationship) (postgres.Relationship, error)
+	DeleteRelationship(ctx context.Context, id string) error
+	ListRelationships(ctx context.Context, q postgres.RelationshipQuery, page postgres.Page) ([]postgres.Relationship, bool, error)
+	ListRelatedPlaces(ctx context.Context, q postgres.RelationshipQuery, page postgres.Page) ([]postgres.Place, bool, error)
+	ListRelatedPeople(ctx context.Context, q postgres.RelationshipQuery, page postgres.Page) ([]postgres.Person, bool, error)
+
+	ListHistory(ctx context.Context, entity, id string, page postgres.Page) ([]postgres.AuditEntry, bool, error)
+	WatchChanges(ctx context.Context, entities []string, fn func(postgres.Change) error) error
 }
 
 type con struct {
-	cat   *cats.Gateway
-	log   *zap.Logger
-	cache redis.Gateway
-	slack slack.Gateway
-	db    postgres.Gateway
-	keys  []string
+	cat     *cats.Gateway
+	log     *zap.Logger
+	cache   redis.Gateway
+	slack   slack.Gateway
+	db      postgres.Gateway
+	feed    *postgres.Feed
+	elector *redis.Elector
+	keys    []string
 }
 
 type Params struct {
 	fx.In
 
-	Cat   *cats.Gateway
-	Cache redis.Gateway
-	Slack slack.Gateway
-	DB    postgres.Gateway
-	Log   *zap.Logger
-	Lc    fx.Lifecycle
+	Cat     *cats.Gateway
+	Cache   redis.Gateway
+	Slack   slack.Gateway
+	DB      postgres.Gateway
+	Feed    *postgres.Feed
+	Elector *redis.Elector
+	Log     *zap.Logger
+	Lc      fx.Lifecycle
 }
 
 // New .
 func New(p Params) Controller {
 	newController := &con{
-		cat:   p.Cat,
-		log:   p.Log,
-		cache: p.Cache,
-		slack: p.Slack,
-		db:    p.DB,
+		cat:     p.Cat,
+		log:     p.Log,
+		cache:   p.Cache,
+		slack:   p.Slack,
+		db:      p.DB,
+		feed:    p.Feed,
+		elector: p.Elector,
 	}
 
 	exitCh := make(chan bool, 1)
@@ -77,9 +119,70 @@ func (c *con) CatFact(ctx context.Context) (string, error) {
 	c.keys = append(c.keys, key)
 	c.cache.Set(ctx, key, fact, 1*time.Minute)
 
+	// Share the fact with watchers on every instance.
+	err = c.cache.Publish(ctx, factsChannel, fact)
+	if err != nil {
+		c.log.Error("publish fact", zap.Error(err))
+	}
+
 	return fact, nil
 }
 
+// WatchFacts calls fn with each fact fetched by any instance
+// until ctx is done, fn fails or the app stops.
+func (c *con) WatchFacts(ctx context.Context, fn func(fact string) error) error {
+	sub, err := c.cache.Subscribe(ctx, factsChannel)
+	if err != nil {
+		return fmt.Errorf("subscribe facts %w", err)
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			err := fn(msg.Payload)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// WatchChanges calls fn with each committed change to entities, table
+// names, or to every entity when none are given, until ctx is done, fn
+// fails or the app stops.
+func (c *con) WatchChanges(
+	ctx context.Context,
+	entities []string,
+	fn func(postgres.Change) error,
+) error {
+	sub, err := c.feed.Subscribe(entities...)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			err := fn(change)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
 func (c *con) listener(exitCh chan bool) {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
@@ -90,6 +193,11 @@ func (c *con) listener(exitCh chan bool) {
 			c.log.Info("closing goroutine")
 			return
 		case t := <-ticker.C:
+			// Only the elected replica runs background work.
+			if !c.elector.IsLeader() {
+				continue
+			}
+
 			ctx := context.Background()
 			c.log.Info("ticker reached", zap.Any("time", t))
 
@@ -97,7 +205,7 @@ func (c *con) listener(exitCh chan bool) {
 			for _, key := range c.keys {
 				value, err := c.cache.Get(ctx, key)
 				if err != nil {
-					if err.Error() == "redis: nil" {
+					if errors.Is(err, redis.Nil) {
 						continue
 					}
 					// could emit err to channel here.
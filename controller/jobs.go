@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/fx"
+
+	"fx-sample-app/gateway/redis"
+	"fx-sample-app/gateway/slack"
+	"fx-sample-app/jobs"
+)
+
+// SlackFollowUpJob replies to a slash command once a cat fact is fetched.
+const SlackFollowUpJob = "slack.cat_fact_follow_up"
+
+// SlackFollowUp is the SlackFollowUpJob payload.
+type SlackFollowUp struct {
+	ResponseURL string `json:"response_url"`
+}
+
+type slackFollowUp struct {
+	con   Controller
+	slack slack.Gateway
+}
+
+// FollowUpParams defines constructor requirements.
+type FollowUpParams struct {
+	fx.In
+
+	Con   Controller
+	Slack slack.Gateway
+}
+
+// NewSlackFollowUp is the SlackFollowUpJob handler constructor.
+func NewSlackFollowUp(p FollowUpParams) jobs.Handler {
+	return &slackFollowUp{
+		con:   p.Con,
+		slack: p.Slack,
+	}
+}
+
+// Type .
+func (s *slackFollowUp) Type() string {
+	return SlackFollowUpJob
+}
+
+// Handle fetches a cat fact and posts it back to slack.
+func (s *slackFollowUp) Handle(ctx context.Context, job redis.Job) error {
+	var followUp SlackFollowUp
+	err := json.Unmarshal(job.Payload, &followUp)
+	if err != nil {
+		return fmt.Errorf("unmarshal payload %w", err)
+	}
+
+	fact, err := s.con.CatFact(ctx)
+	if err != nil {
+		return fmt.Errorf("CatFact %w", err)
+	}
+
+	return s.slack.RespondToCommand(ctx, followUp.ResponseURL, fact)
+}
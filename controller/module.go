@@ -1,8 +1,15 @@
 package controller
 
-import "go.uber.org/fx"
+import (
+	"go.uber.org/fx"
+
+	"fx-sample-app/jobs"
+)
 
 var Module = fx.Module(
 	"controller",
-	fx.Provide(New),
+	fx.Provide(
+		New,
+		jobs.AsHandler(NewSlackFollowUp),
+	),
 )
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fx-sample-app/gateway/postgres"
+	"fx-sample-app/records"
+)
+
+// CreatePerson assigns an id and timestamp when unset and stores p.
+func (c *con) CreatePerson(ctx context.Context, p postgres.Person) (postgres.Person, error) {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	if p.Timestamp == 0 {
+		p.Timestamp = time.Now().UTC().Unix()
+	}
+
+	return c.db.CreatePerson(ctx, p)
+}
+
+// GetPerson returns the person with id.
+func (c *con) GetPerson(ctx context.Context, id string) (postgres.Person, error) {
+	return c.db.GetPerson(ctx, id)
+}
+
+// UpdatePerson sets columns of the person with p's id.
+func (c *con) UpdatePerson(
+	ctx context.Context,
+	p postgres.Person,
+	columns []string,
+) (postgres.Person, error) {
+	return c.db.UpdatePerson(ctx, p, columns)
+}
+
+// DeletePerson removes the person with id.
+func (c *con) DeletePerson(ctx context.Context, id string) error {
+	return c.db.DeletePerson(ctx, id)
+}
+
+// ListPeople returns a page of people and whether more follow.
+func (c *con) ListPeople(ctx context.Context, page postgres.Page) ([]postgres.Person, bool, error) {
+	return c.db.ListPeople(ctx, postgres.Filter{}, page)
+}
+
+// EstimatePeople estimates how many people are stored.
+func (c *con) EstimatePeople(ctx context.Context) (int64, error) {
+	return c.db.EstimatePeople(ctx, postgres.Filter{})
+}
+
+// ImportPeople stores people read from r in one transaction.
+func (c *con) ImportPeople(
+	ctx context.Context,
+	r io.Reader,
+	format records.Format,
+	upsert bool,
+) (int64, error) {
+	return records.ImportPeople(ctx, c.db, r, format, upsert)
+}
+
+// ExportPeople writes every person to w.
+func (c *con) ExportPeople(ctx context.Context, w io.Writer, format records.Format) error {
+	return records.ExportPeople(ctx, c.db, w, format)
+}
+
+// CreatePlace assigns an id and timestamp when unset and stores p.
+func (c *con) CreatePlace(ctx context.Context, p postgres.Place) (postgres.Place, error) {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	if p.Timestamp == 0 {
+		p.Timestamp = time.Now().UTC().Unix()
+	}
+	records.StampComments(p.Comments, p.Timestamp)
+
+	return c.db.CreatePlace(ctx, p)
+}
+
+// GetPlace returns the place with id.
+func (c *con) GetPlace(ctx context.Context, id string) (postgres.Place, error) {
+	return c.db.GetPlace(ctx, id)
+}
+
+// UpdatePlace sets columns of the place with p's id.
+func (c *con) UpdatePlace(
+	ctx context.Context,
+	p postgres.Place,
+	columns []string,
+) (postgres.Place, error) {
+	return c.db.UpdatePlace(ctx, p, columns)
+}
+
+// DeletePlace removes the place with id.
+func (c *con) DeletePlace(ctx context.Context, id string) error {
+	return c.db.DeletePlace(ctx, id)
+}
+
+// AddPlaceComment assigns cm an id and creation time and adds it to
+// the place with placeID.
+func (c *con) AddPlaceComment(
+	ctx context.Context,
+	placeID string,
+	cm postgres.Comment,
+) (postgres.Comment, error) {
+	cm.ID = uuid.New().String()
+	cm.CreatedAt = time.Now().UTC().Unix()
+
+	err := c.db.AddPlaceComment(ctx, placeID, cm, records.MaxComments)
+	if err != nil {
+		return postgres.Comment{}, err
+	}
+
+	return cm, nil
+}
+
+// RemovePlaceComment removes a comment from the place with placeID.
+func (c *con) RemovePlaceComment(ctx context.Context, placeID, commentID string) error {
+	return c.db.RemovePlaceComment(ctx, placeID, commentID)
+}
+
+// ListPlaces returns a page of places and whether more follow.
+func (c *con) ListPlaces(ctx context.Context, page postgres.Page) ([]postgres.Place, bool, error) {
+	return c.db.SelectPlaceByFilter(ctx, postgres.Filter{}, page)
+}
+
+// EstimatePlaces estimates how many places are stored.
+func (c *con) EstimatePlaces(ctx context.Context) (int64, error) {
+	return c.db.EstimatePlaces(ctx, postgres.Filter{})
+}
+
+// ImportPlaces stores places read from r in one transaction.
+func (c *con) ImportPlaces(
+	ctx context.Context,
+	r io.Reader,
+	format records.Format,
+	upsert bool,
+) (int64, error) {
+	return records.ImportPlaces(ctx, c.db, r, format, upsert)
+}
+
+// ExportPlaces writes every place to w.
+func (c *con) ExportPlaces(ctx context.Context, w io.Writer, format records.Format) error {
+	return records.ExportPlaces(ctx, c.db, w, format)
+}
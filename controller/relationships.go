@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+// CreateRelationship assigns an id and timestamp when unset and stores r.
+func (c *con) CreateRelationship(
+	ctx context.Context,
+	r postgres.Relationship,
+) (postgres.Relationship, error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	if r.Timestamp == 0 {
+		r.Timestamp = time.Now().UTC().Unix()
+	}
+
+	return c.db.CreateRelationship(ctx, r)
+}
+
+// DeleteRelationship removes the relationship with id.
+func (c *con) DeleteRelationship(ctx context.Context, id string) error {
+	return c.db.DeleteRelationship(ctx, id)
+}
+
+// ListRelationships returns a page of relationships matching q and
+// whether more follow.
+func (c *con) ListRelationships(
+	ctx context.Context,
+	q postgres.RelationshipQuery,
+	page postgres.Page,
+) ([]postgres.Relationship, bool, error) {
+	return c.db.ListRelationships(ctx, q.Filter(), page)
+}
+
+// ListRelatedPlaces returns a page of places with a relationship
+// matching q, each listed once.
+func (c *con) ListRelatedPlaces(
+	ctx context.Context,
+	q postgres.RelationshipQuery,
+	page postgres.Page,
+) ([]postgres.Place, bool, error) {
+	return c.db.SelectPlaceByFilter(ctx, postgres.PlacesRelated(q.Filter()), page)
+}
+
+// ListRelatedPeople returns a page of people with a relationship
+// matching q, each listed once.
+func (c *con) ListRelatedPeople(
+	ctx context.Context,
+	q postgres.RelationshipQuery,
+	page postgres.Page,
+) ([]postgres.Person, bool, error) {
+	return c.db.ListPeople(ctx, postgres.PeopleRelated(q.Filter()), page)
+}
+
+// ListHistory returns a page of the recorded changes to the entity
+// with id, entity being its table, and whether more follow.
+func (c *con) ListHistory(
+	ctx context.Context,
+	entity string,
+	id string,
+	page postgres.Page,
+) ([]postgres.AuditEntry, bool, error) {
+	return c.db.ListHistory(ctx, entity, id, page)
+}
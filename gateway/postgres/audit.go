@@ -0,0 +1,287 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Operation is an audited change.
+type Operation string
+
+// Audited operations.
+const (
+	AuditCreate Operation = "create"
+	AuditUpdate Operation = "update"
+	AuditDelete Operation = "delete"
+	AuditImport Operation = "import"
+)
+
+// systemActor is recorded for changes made without an actor, such as
+// from the command line.
+const systemActor = "system"
+
+// AuditEntry corresponds to the audit_log table.
+type AuditEntry struct {
+	ID        int64     `json:"id"               db:"id"`
+	Entity    string    `json:"entity"           db:"entity"`
+	EntityID  string    `json:"entity_id"        db:"entity_id"`
+	Operation Operation `json:"operation"        db:"operation"`
+	Actor     string    `json:"actor"            db:"actor"`
+	// Before and After hold the changed columns, or the whole row
+	// when it is created, deleted or imported.
+	Before RawJSON `json:"before,omitempty" db:"before"`
+	After  RawJSON `json:"after,omitempty"  db:"after"`
+	// At is in unix seconds.
+	At int64 `json:"at" db:"at"`
+}
+
+// RawJSON is a jsonb document, NULL when empty.
+type RawJSON []byte
+
+// jsonb marks RawJSON as a jsonb column.
+func (RawJSON) jsonb() {}
+
+// Value implements driver.Valuer.
+func (r RawJSON) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	return string(r), nil
+}
+
+// Scan implements sql.Scanner.
+func (r *RawJSON) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		*r = append(RawJSON(nil), src...)
+		return nil
+	case string:
+		*r = RawJSON(src)
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into RawJSON", src)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r RawJSON) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+// actorKey is the context key of WithActor.
+type actorKey struct{}
+
+// WithActor returns a context whose changes are audited as made by actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorOf returns the actor of ctx, systemActor when unset.
+func actorOf(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	if actor == "" {
+		return systemActor
+	}
+	return actor
+}
+
+// ListHistory returns a page of the audit entries of the entity with
+// id, entity being its table name, and whether more follow.
+func (g *gateway) ListHistory(
+	ctx context.Context,
+	entity string,
+	id string,
+	page Page,
+) ([]AuditEntry, bool, error) {
+	ctx, cancel := g.timeout(ctx)
+	defer cancel()
+
+	var entries []AuditEntry
+	var more bool
+	f := And(Where("entity", OpEq, entity), Where("entity_id", OpEq, id))
+	err := g.read(ctx, func(db *sqlx.DB) error {
+		entries = nil
+		var err error
+		more, err = list(ctx, db, "audit_log", &entries, f, page)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return entries, more, nil
+}
+
+// audited runs fn in a transaction and records op on the row of table
+// with id in the same transaction, along with the row before and
+// after, and adds an outbox event for it. Rows that are missing or
+// soft deleted fail with ErrNotFound before fn runs, unless op creates
+// them, and rows at another version than ctx expects fail with
+// ErrVersionMismatch.
+func (g *gateway) audited(
+	ctx context.Context,
+	table string,
+	id string,
+	op Operation,
+	fn func(tx *sqlx.Tx) error,
+) error {
+	ctx, cancel := g.timeout(ctx)
+	defer cancel()
+
+	return g.WithTx(ctx, nil, func(tx *sqlx.Tx) error {
+		var before, after RawJSON
+		var err error
+		if op != AuditCreate {
+			before, err = snapshot(ctx, tx, table, id)
+			if err != nil {
+				return err
+			}
+			err = checkVersion(ctx, table, id, before)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
+
+		payload := before
+		if op != AuditDelete {
+			after, err = snapshot(ctx, tx, table, id)
+			if err != nil {
+				return err
+			}
+			payload = after
+		}
+		if op == AuditUpdate {
+			before, after, err = diff(before, after)
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now().UTC().Unix()
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO "audit_log" ("entity", "entity_id", "operation", "actor", "before", "after", "at")
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			table,
+			id,
+			string(op),
+			actorOf(ctx),
+			before,
+			after,
+			now,
+		)
+		if err != nil {
+			return fmt.Errorf("audit %w", err)
+		}
+		return writeOutbox(ctx, tx, table, id, op, payload, now)
+	})
+}
+
+// snapshot locks the live row of table with id and returns it as JSON.
+func snapshot(ctx context.Context, tx *sqlx.Tx, table, id string) (RawJSON, error) {
+	var row RawJSON
+	err := tx.GetContext(ctx, &row, fmt.Sprintf(
+		`SELECT to_jsonb(t) FROM %s AS t WHERE "id" = $1 AND %s IS NULL FOR UPDATE`,
+		pq.QuoteIdentifier(table),
+		pq.QuoteIdentifier(deletedColumn),
+	), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s %s %w", table, id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %w", err)
+	}
+	return row, nil
+}
+
+// diff reduces two snapshots of a row to the columns that changed.
+// updated_at and version change with every update and are left out.
+func diff(before, after RawJSON) (RawJSON, RawJSON, error) {
+	var old, changed map[string]json.RawMessage
+	err := json.Unmarshal(before, &old)
+	if err != nil {
+		return nil, nil, fmt.Errorf("diff %w", err)
+	}
+	err = json.Unmarshal(after, &changed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("diff %w", err)
+	}
+
+	for name, value := range changed {
+		if name == "updated_at" || name == versionColumn || bytes.Equal(old[name], value) {
+			delete(old, name)
+			delete(changed, name)
+		}
+	}
+
+	before, err = json.Marshal(old)
+	if err != nil {
+		return nil, nil, fmt.Errorf("diff %w", err)
+	}
+	after, err = json.Marshal(changed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("diff %w", err)
+	}
+	return before, after, nil
+}
+
+// touch returns a copy of row with updated_at set to now, and on
+// create an unset created_at and version too, for tables with those
+// columns.
+func touch(row interface{}, now int64, create bool) (interface{}, error) {
+	v := reflect.New(reflect.TypeOf(row)).Elem()
+	v.Set(reflect.ValueOf(row))
+	t, err := tableOf(v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	if col, ok := t.byName["updated_at"]; ok {
+		v.Field(col.index).SetInt(now)
+	}
+	if col, ok := t.byName["created_at"]; ok && create && v.Field(col.index).Int() == 0 {
+		v.Field(col.index).SetInt(now)
+	}
+	if col, ok := t.byName[versionColumn]; ok && create && v.Field(col.index).Int() == 0 {
+		v.Field(col.index).SetInt(1)
+	}
+	return v.Interface(), nil
+}
+
+// idOf returns the id of row.
+func idOf(row interface{}) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	t, err := tableOf(v.Type())
+	if err != nil {
+		return "", err
+	}
+	col, ok := t.byName["id"]
+	if !ok {
+		return "", fmt.Errorf("%w: %s has no id column", ErrInvalidFilter, v.Type())
+	}
+	id, ok := v.Field(col.index).Interface().(string)
+	if !ok {
+		return "", fmt.Errorf("%w: %s id is not a string", ErrInvalidFilter, v.Type())
+	}
+	return id, nil
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrLimitExceeded is returned when a row holds too many of something.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Comment is a note on a place. Fields hold key/value data that
+// filters can match by containment. Unset fields are left out of the
+// JSON, so a partial comment matches comments it is contained in.
+type Comment struct {
+	ID     string            `json:"id,omitempty"`
+	Author string            `json:"author,omitempty"`
+	Text   string            `json:"text,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	// CreatedAt is in unix seconds.
+	CreatedAt int64 `json:"created_at,omitempty"`
+}
+
+// Comments are stored as a jsonb array.
+type Comments []Comment
+
+// jsonb marks Comments as a jsonb column.
+func (Comments) jsonb() {}
+
+// Value implements driver.Valuer. Text is returned so both queries and
+// COPY send it as jsonb input.
+func (c Comments) Value() (driver.Value, error) {
+	if c == nil {
+		c = Comments{}
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal %w", err)
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner.
+func (c *Comments) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	}
+	return fmt.Errorf("cannot scan %T into Comments", src)
+}
+
+// CommentWith matches places with a comment whose fields set key to value.
+func CommentWith(key, value string) Filter {
+	return Where("comments", OpContains, []map[string]map[string]string{
+		{"fields": {key: value}},
+	})
+}
+
+// CommentBy matches places with a comment by author.
+func CommentBy(author string) Filter {
+	return Where("comments", OpContains, []map[string]string{
+		{"author": author},
+	})
+}
+
+// AddPlaceComment appends c to the comments of the place with id,
+// failing with ErrLimitExceeded when it already has max comments.
+func (g *gateway) AddPlaceComment(ctx context.Context, id string, c Comment, max int) error {
+	value, err := Comments{c}.Value()
+	if err != nil {
+		return err
+	}
+
+	return g.audited(ctx, "place", id, AuditUpdate, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE "place" SET "comments" = "comments" || $1::jsonb, "updated_at" = $2,
+				"version" = "version" + 1
+			WHERE "id" = $3 AND jsonb_array_length("comments") < $4`,
+			value,
+			time.Now().UTC().Unix(),
+			id,
+			max,
+		)
+		if err != nil {
+			return fmt.Errorf("ExecContext %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("RowsAffected %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("place comments %w: at most %d", ErrLimitExceeded, max)
+		}
+		return nil
+	})
+}
+
+// RemovePlaceComment removes the comment with commentID from the place with id.
+func (g *gateway) RemovePlaceComment(ctx context.Context, id, commentID string) error {
+	match, err := json.Marshal([]map[string]string{{"id": commentID}})
+	if err != nil {
+		return fmt.Errorf("json.Marshal %w", err)
+	}
+
+	return g.audited(ctx, "place", id, AuditUpdate, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE "place" SET "comments" = (
+				SELECT coalesce(jsonb_agg(c ORDER BY n), '[]'::jsonb)
+				FROM jsonb_array_elements("comments") WITH ORDINALITY AS e (c, n)
+				WHERE c->>'id' <> $1
+			), "updated_at" = $2, "version" = "version" + 1
+			WHERE "id" = $3 AND "comments" @> $4::jsonb`,
+			commentID,
+			time.Now().UTC().Unix(),
+			id,
+			string(match),
+		)
+		if err != nil {
+			return fmt.Errorf("ExecContext %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("RowsAffected %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("place comment %w", ErrNotFound)
+		}
+		return nil
+	})
+}
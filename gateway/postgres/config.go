@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config defines the postgres connection settings under the postgres key.
+type Config struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	DBName   string `yaml:"db_name"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// SSLMode is one of disable, require, verify-ca or verify-full.
+	SSLMode string `yaml:"ssl_mode"`
+	// ConnectTimeout bounds each dial, rounded up to whole seconds.
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+	ApplicationName string        `yaml:"application_name"`
+	Pool            PoolConfig    `yaml:"pool"`
+	TLS             TLSConfig     `yaml:"tls"`
+	// QueryTimeout bounds each Gateway call, zero for none.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+	Tx           TxConfig      `yaml:"tx"`
+	// MigrateOnStart applies pending migrations as the app starts.
+	MigrateOnStart bool          `yaml:"migrate_on_start"`
+	Listen         ListenConfig  `yaml:"listen"`
+	Replicas       ReplicaConfig `yaml:"replicas"`
+	// Instrument times, logs and counts queries.
+	Instrument InstrumentConfig `yaml:"instrument"`
+}
+
+// PoolConfig defines database/sql pool settings. Zero values use its defaults.
+type PoolConfig struct {
+	MaxOpen         int           `yaml:"max_open"`
+	MaxIdle         int           `yaml:"max_idle"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+// TLSConfig defines certificate files used when ssl_mode is not disable.
+type TLSConfig struct {
+	// RootCert is a PEM bundle used to verify the server.
+	RootCert string `yaml:"root_cert"`
+	// Cert and Key are an optional client certificate pair.
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+// sslModes are the modes lib/pq supports.
+var sslModes = map[string]bool{
+	"disable":     true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// validate checks settings lib/pq would only reject on first connect.
+func (c Config) validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("postgres host required")
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid postgres port %d", c.Port)
+	}
+	if c.DBName == "" || c.User == "" {
+		return fmt.Errorf("postgres db_name and user required")
+	}
+	if !sslModes[c.SSLMode] {
+		return fmt.Errorf("unsupported ssl_mode %q", c.SSLMode)
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		return fmt.Errorf("tls cert and key must be set together")
+	}
+	if c.SSLMode == "disable" && (c.TLS.RootCert != "" || c.TLS.Cert != "") {
+		return fmt.Errorf("tls certs set with ssl_mode disable")
+	}
+	return nil
+}
+
+// dsn builds a lib/pq key value connection string.
+func (c Config) dsn() string {
+	return c.connString(c.Password)
+}
+
+// sanitizedDSN is the connection string with the password redacted, for logs.
+func (c Config) sanitizedDSN() string {
+	if c.Password == "" {
+		return c.connString("")
+	}
+	return c.connString("REDACTED")
+}
+
+// connString renders the settings with password.
+func (c Config) connString(password string) string {
+	var params []string
+	add := func(key, value string) {
+		if value != "" {
+			params = append(params, key+"="+quoteDSN(value))
+		}
+	}
+
+	add("host", c.Host)
+	if c.Port != 0 {
+		add("port", strconv.Itoa(c.Port))
+	}
+	add("dbname", c.DBName)
+	add("user", c.User)
+	add("password", password)
+	add("sslmode", c.SSLMode)
+	add("sslrootcert", c.TLS.RootCert)
+	add("sslcert", c.TLS.Cert)
+	add("sslkey", c.TLS.Key)
+	if c.ConnectTimeout > 0 {
+		seconds := (c.ConnectTimeout + time.Second - 1) / time.Second
+		add("connect_timeout", strconv.Itoa(int(seconds)))
+	}
+	add("application_name", c.ApplicationName)
+
+	return strings.Join(params, " ")
+}
+
+// quoteDSN quotes a connection string value when it needs it.
+func quoteDSN(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
@@ -0,0 +1,255 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ImportPeople copies people returned by next into the person table
+// in one transaction, until next returns io.EOF. Any other error from
+// next rolls the import back. With upsert, rows whose id is taken
+// replace the stored row, keeping its created_at and deleted_at when
+// unset, otherwise they fail with ErrAlreadyExists.
+func (g *gateway) ImportPeople(
+	ctx context.Context,
+	next func() (Person, error),
+	upsert bool,
+) (int64, error) {
+	return g.copyIn(ctx, "person", Person{}, func() (interface{}, error) {
+		return next()
+	}, upsert)
+}
+
+// ExportPeople calls fn for every person in id order.
+func (g *gateway) ExportPeople(ctx context.Context, fn func(Person) error) error {
+	return g.copyOut(ctx, "person", Person{}, func(row interface{}) error {
+		return fn(row.(Person))
+	})
+}
+
+// ImportPlaces copies places returned by next into the place table,
+// see ImportPeople.
+func (g *gateway) ImportPlaces(
+	ctx context.Context,
+	next func() (Place, error),
+	upsert bool,
+) (int64, error) {
+	return g.copyIn(ctx, "place", Place{}, func() (interface{}, error) {
+		return next()
+	}, upsert)
+}
+
+// ExportPlaces calls fn for every place in id order.
+func (g *gateway) ExportPlaces(ctx context.Context, fn func(Place) error) error {
+	return g.copyOut(ctx, "place", Place{}, func(row interface{}) error {
+		return fn(row.(Place))
+	})
+}
+
+// copyIn streams rows into a temporary table with COPY, then moves
+// them into name with a single statement so conflicts can be resolved,
+// auditing every row as imported and adding its outbox event. Rows
+// must have distinct ids. Bulk statements run without the query
+// timeout.
+func (g *gateway) copyIn(
+	ctx context.Context,
+	name string,
+	row interface{},
+	next func() (interface{}, error),
+	upsert bool,
+) (int64, error) {
+	t, err := tableOf(reflect.TypeOf(row))
+	if err != nil {
+		return 0, err
+	}
+	stage := name + "_import"
+	columns := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		columns[i] = col.name
+	}
+
+	query := moveQuery(name, t, stage, upsert)
+
+	// The input cannot be read twice, so this is a single attempt at
+	// the default isolation rather than WithTx.
+	var imported int64
+	err = g.runTx(ctx, &sql.TxOptions{}, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP",
+			pq.QuoteIdentifier(stage),
+			pq.QuoteIdentifier(name),
+		))
+		if err != nil {
+			return fmt.Errorf("create %s %w", stage, err)
+		}
+
+		stmt, err := tx.PrepareContext(ctx, pq.CopyIn(stage, columns...))
+		if err != nil {
+			return fmt.Errorf("PrepareContext %w", err)
+		}
+		defer stmt.Close()
+
+		now := time.Now().UTC().Unix()
+		args := make([]interface{}, len(t.columns))
+		for {
+			r, err := next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			// Creation times and versions are set as rows move.
+			r, err = touch(r, now, false)
+			if err != nil {
+				return err
+			}
+
+			v := reflect.ValueOf(r)
+			for i, col := range t.columns {
+				args[i] = v.Field(col.index).Interface()
+			}
+			_, err = stmt.ExecContext(ctx, args...)
+			if err != nil {
+				return fmt.Errorf("copy %w", err)
+			}
+		}
+
+		// An Exec without arguments ends the COPY.
+		_, err = stmt.ExecContext(ctx)
+		if err != nil {
+			return fmt.Errorf("copy %w", err)
+		}
+
+		res, err := tx.ExecContext(
+			ctx,
+			query,
+			name,
+			string(AuditImport),
+			actorOf(ctx),
+			now,
+			name+"."+string(AuditImport),
+		)
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s %w: %v", name, ErrAlreadyExists, err)
+		}
+		if err != nil {
+			return fmt.Errorf("ExecContext %w", err)
+		}
+
+		imported, err = res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("RowsAffected %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return imported, nil
+}
+
+// moveQuery builds the statement moving the rows of stage into name,
+// auditing each with the row it replaced and adding its outbox event.
+// It takes the arguments entity, operation, actor, time and topic, and
+// affects a row per row moved. A zero created_at is the time for new
+// rows, and with upsert keeps the stored one as does a null deleted_at.
+func moveQuery(name string, t *table, stage string, upsert bool) string {
+	values := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		quoted := "s." + pq.QuoteIdentifier(col.name)
+		switch col.name {
+		case "created_at":
+			values[i] = fmt.Sprintf(`CASE WHEN r."id" IS NULL AND %s = 0 THEN $4 ELSE %s END`, quoted, quoted)
+		case versionColumn:
+			values[i] = fmt.Sprintf("greatest(%s, 1)", quoted)
+		default:
+			values[i] = quoted
+		}
+	}
+	var conflict string
+	if upsert {
+		conflict = t.onConflictUpdate(name, true)
+	}
+
+	// Every part reads the rows as they were before the statement, so
+	// replaced holds the rows before they are replaced.
+	return fmt.Sprintf(`WITH "replaced" AS (
+	SELECT t.* FROM %[1]s AS t JOIN %[2]s AS s USING ("id")
+), "moved" AS (
+	INSERT INTO %[1]s (%[3]s)
+	SELECT %[4]s FROM %[2]s AS s LEFT JOIN "replaced" AS r USING ("id")%[5]s
+	RETURNING %[1]s.*
+), "audited" AS (
+	INSERT INTO "audit_log" ("entity", "entity_id", "operation", "actor", "before", "after", "at")
+	SELECT $1, m."id", $2, $3, CASE WHEN r."id" IS NULL THEN NULL ELSE to_jsonb(r) END, to_jsonb(m), $4
+	FROM "moved" AS m LEFT JOIN "replaced" AS r USING ("id")
+)
+INSERT INTO "outbox" ("topic", "entity", "entity_id", "operation", "actor", "payload",
+	"created_at", "next_attempt_at")
+SELECT $5, $1, m."id", $2, $3, to_jsonb(m), $4, $4 FROM "moved" AS m ORDER BY m."id"`,
+		pq.QuoteIdentifier(name),
+		pq.QuoteIdentifier(stage),
+		t.names(),
+		strings.Join(values, ", "),
+		conflict,
+	)
+}
+
+// copyOut scans every visible row of name in id order, calling fn
+// with each. Bulk statements run without the query timeout.
+func (g *gateway) copyOut(
+	ctx context.Context,
+	name string,
+	row interface{},
+	fn func(row interface{}) error,
+) error {
+	typ := reflect.TypeOf(row)
+	t, err := tableOf(typ)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", t.names(), pq.QuoteIdentifier(name))
+	if t.softDeletes() && !includesDeleted(ctx) {
+		query += fmt.Sprintf(" WHERE %s IS NULL", pq.QuoteIdentifier(deletedColumn))
+	}
+	var rows *sqlx.Rows
+	err = g.read(ctx, func(db *sqlx.DB) error {
+		var err error
+		rows, err = db.QueryxContext(ctx, query+` ORDER BY "id"`)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("QueryxContext %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		dest := reflect.New(typ)
+		err = rows.StructScan(dest.Interface())
+		if err != nil {
+			return fmt.Errorf("StructScan %w", err)
+		}
+		err = fn(dest.Elem().Interface())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("rows %w", err)
+	}
+	return nil
+}
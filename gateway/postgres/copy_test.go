@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMoveQueryGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		table  string
+		row    interface{}
+		upsert bool
+	}{
+		{"insert", "person", Person{}, false},
+		{"upsert", "person", Person{}, true},
+		{"upsert_place", "place", Place{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb, err := tableOf(reflect.TypeOf(tt.row))
+			if err != nil {
+				t.Fatalf("tableOf: %v", err)
+			}
+			golden(t, "move_"+tt.name, moveQuery(tt.table, tb, tt.table+"_import", tt.upsert)+"\n")
+		})
+	}
+}
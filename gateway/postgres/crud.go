@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CreatePerson inserts a person and returns it as stored.
+func (g *gateway) CreatePerson(ctx context.Context, p Person) (Person, error) {
+	var created Person
+	err := g.insert(ctx, "person", &created, p)
+	return created, err
+}
+
+// GetPerson returns the person with id.
+func (g *gateway) GetPerson(ctx context.Context, id string) (Person, error) {
+	var p Person
+	err := g.get(ctx, "person", &p, id)
+	return p, err
+}
+
+// UpdatePerson sets columns of the person with p's id.
+func (g *gateway) UpdatePerson(ctx context.Context, p Person, columns []string) (Person, error) {
+	var updated Person
+	err := g.update(ctx, "person", &updated, p, columns)
+	return updated, err
+}
+
+// DeletePerson removes the person with id.
+func (g *gateway) DeletePerson(ctx context.Context, id string) error {
+	return g.delete(ctx, "person", id)
+}
+
+// ListPeople returns a page of people matching f and whether more follow.
+func (g *gateway) ListPeople(ctx context.Context, f Filter, page Page) ([]Person, bool, error) {
+	ctx, cancel := g.timeout(ctx)
+	defer cancel()
+
+	var people []Person
+	var more bool
+	err := g.read(ctx, func(db *sqlx.DB) error {
+		people = nil
+		var err error
+		more, err = list(ctx, db, "person", &people, f, page)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return people, more, nil
+}
+
+// EstimatePeople estimates how many people match f.
+func (g *gateway) EstimatePeople(ctx context.Context, f Filter) (int64, error) {
+	return g.estimate(ctx, "person", Person{}, f)
+}
+
+// CreatePlace inserts a place and returns it as stored.
+func (g *gateway) CreatePlace(ctx context.Context, p Place) (Place, error) {
+	var created Place
+	err := g.insert(ctx, "place", &created, p)
+	return created, err
+}
+
+// GetPlace returns the place with id.
+func (g *gateway) GetPlace(ctx context.Context, id string) (Place, error) {
+	var p Place
+	err := g.get(ctx, "place", &p, id)
+	return p, err
+}
+
+// UpdatePlace sets columns of the place with p's id.
+func (g *gateway) UpdatePlace(ctx context.Context, p Place, columns []string) (Place, error) {
+	var updated Place
+	err := g.update(ctx, "place", &updated, p, columns)
+	return updated, err
+}
+
+// DeletePlace removes the place with id.
+func (g *gateway) DeletePlace(ctx context.Context, id string) error {
+	return g.delete(ctx, "place", id)
+}
+
+// EstimatePlaces estimates how many places match f.
+func (g *gateway) EstimatePlaces(ctx context.Context, f Filter) (int64, error) {
+	return g.estimate(ctx, "place", Place{}, f)
+}
+
+// insert adds row to table and scans the stored row into dest,
+// ErrAlreadyExists when its id is taken.
+func (g *gateway) insert(ctx context.Context, table string, dest, row interface{}) error {
+	id, err := idOf(row)
+	if err != nil {
+		return err
+	}
+
+	return g.audited(ctx, table, id, AuditCreate, func(tx *sqlx.Tx) error {
+		return insertRow(ctx, tx, table, dest, row)
+	})
+}
+
+// insertRow adds row to table in tx, stamping its change times, and
+// scans the stored row into dest.
+func insertRow(ctx context.Context, tx *sqlx.Tx, table string, dest, row interface{}) error {
+	row, err := touch(row, time.Now().UTC().Unix(), true)
+	if err != nil {
+		return err
+	}
+	t, err := tableOf(reflect.TypeOf(row))
+	if err != nil {
+		return err
+	}
+	query, args, err := insertQuery(table, row)
+	if err != nil {
+		return fmt.Errorf("insertQuery %w", err)
+	}
+
+	err = tx.GetContext(ctx, dest, query+" RETURNING "+t.names(), args...)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%s %w", table, ErrAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("GetContext %w", err)
+	}
+
+	return nil
+}
+
+// get scans the table row with id into dest. Soft deleted rows are
+// not found unless ctx includes them.
+func (g *gateway) get(ctx context.Context, table string, dest interface{}, id string) error {
+	ctx, cancel := g.timeout(ctx)
+	defer cancel()
+
+	f, err := visible(ctx, dest, Where("id", OpEq, id))
+	if err != nil {
+		return err
+	}
+	query, args, err := selectQuery(table, dest, f)
+	if err != nil {
+		return fmt.Errorf("selectQuery %w", err)
+	}
+
+	err = g.read(ctx, func(db *sqlx.DB) error {
+		return db.GetContext(ctx, dest, query, args...)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s %s %w", table, id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("GetContext %w", err)
+	}
+
+	return nil
+}
+
+// update sets columns from row and scans the result into dest. Soft
+// deleted rows are not found.
+func (g *gateway) update(ctx context.Context, table string, dest, row interface{}, columns []string) error {
+	id, err := idOf(row)
+	if err != nil {
+		return err
+	}
+	row, err = touch(row, time.Now().UTC().Unix(), false)
+	if err != nil {
+		return err
+	}
+	t, err := tableOf(reflect.TypeOf(row))
+	if err != nil {
+		return err
+	}
+	if _, ok := t.byName["updated_at"]; ok {
+		columns = append(columns[:len(columns):len(columns)], "updated_at")
+	}
+
+	query, args, err := updateQuery(table, row, columns)
+	if err != nil {
+		return fmt.Errorf("updateQuery %w", err)
+	}
+
+	return g.audited(ctx, table, id, AuditUpdate, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, dest, query, args...)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s %w", table, ErrNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("GetContext %w", err)
+		}
+		return nil
+	})
+}
+
+// delete soft deletes the table row with id.
+func (g *gateway) delete(ctx context.Context, table, id string) error {
+	return g.audited(ctx, table, id, AuditDelete, func(tx *sqlx.Tx) error {
+		now := time.Now().UTC().Unix()
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`UPDATE %s SET "deleted_at" = $1, "updated_at" = $1, "version" = "version" + 1
+			WHERE "id" = $2`,
+			pq.QuoteIdentifier(table),
+		), now, id)
+		if err != nil {
+			return fmt.Errorf("ExecContext %w", err)
+		}
+		return nil
+	})
+}
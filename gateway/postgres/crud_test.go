@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// echoConnector opens connections answering INSERT ... RETURNING with
+// the inserted values, as Postgres does without defaults or triggers.
+type echoConnector struct {
+	columns []string
+}
+
+func (c echoConnector) Connect(context.Context) (driver.Conn, error) {
+	return echoConn(c), nil
+}
+
+func (c echoConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// echoConn is a connection of echoConnector.
+type echoConn struct {
+	columns []string
+}
+
+func (c echoConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.Contains(query, " RETURNING ") {
+		return nil, errors.New("insert does not return the stored row")
+	}
+	row := make([]driver.Value, len(args))
+	for i, arg := range args {
+		row[i] = arg.Value
+	}
+	return &echoRows{columns: c.columns, row: row}, nil
+}
+
+func (c echoConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c echoConn) Close() error {
+	return nil
+}
+
+func (c echoConn) Begin() (driver.Tx, error) {
+	return echoTx{}, nil
+}
+
+// echoTx is a transaction of echoConn.
+type echoTx struct{}
+
+func (echoTx) Commit() error   { return nil }
+func (echoTx) Rollback() error { return nil }
+
+// echoRows returns row once.
+type echoRows struct {
+	columns []string
+	row     []driver.Value
+	done    bool
+}
+
+func (r *echoRows) Columns() []string {
+	return r.columns
+}
+
+func (r *echoRows) Close() error {
+	return nil
+}
+
+func (r *echoRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.row)
+	return nil
+}
+
+func TestInsertRowReturnsStoredRow(t *testing.T) {
+	tb, err := tableOf(reflect.TypeOf(Person{}))
+	if err != nil {
+		t.Fatalf("tableOf: %v", err)
+	}
+	columns := make([]string, len(tb.columns))
+	for i, col := range tb.columns {
+		columns[i] = col.name
+	}
+	db := sqlx.NewDb(sql.OpenDB(echoConnector{columns: columns}), "postgres")
+	defer db.Close()
+
+	tx, err := db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("BeginTxx: %v", err)
+	}
+	defer tx.Rollback()
+
+	var created Person
+	err = insertRow(context.Background(), tx, "person", &created, Person{ID: "a", FirstName: "Ada"})
+	if err != nil {
+		t.Fatalf("insertRow: %v", err)
+	}
+	if created.ID != "a" || created.FirstName != "Ada" {
+		t.Fatalf("created %+v, want the inserted person", created)
+	}
+	if created.Version != 1 || created.CreatedAt == 0 || created.UpdatedAt != created.CreatedAt {
+		t.Fatalf("created %+v, want version 1 and change times set", created)
+	}
+}
@@ -0,0 +1,463 @@
+package postgres
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// Op is a filter comparison operator.
+type Op string
+
+// Supported operators.
+const (
+	OpEq       Op = "="
+	OpNe       Op = "!="
+	OpLt       Op = "<"
+	OpLte      Op = "<="
+	OpGt       Op = ">"
+	OpGte      Op = ">="
+	OpIn       Op = "IN"
+	OpLike     Op = "LIKE"
+	OpIsNull   Op = "IS NULL"
+	OpNotNull  Op = "IS NOT NULL"
+	OpContains Op = "@>"
+)
+
+// ErrInvalidFilter is wrapped by every filter validation error.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Filter is a condition on Column, or an And or Or group of filters.
+// The zero Filter matches every row.
+type Filter struct {
+	Column string      `json:"column,omitempty"`
+	Op     Op          `json:"op,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	And    []Filter    `json:"and,omitempty"`
+	Or     []Filter    `json:"or,omitempty"`
+}
+
+// Subquery selects Column of the live rows of Table matching Filter,
+// Row being the row struct of Table. It is an OpIn value, matching
+// rows related through another table.
+type Subquery struct {
+	Table  string
+	Row    interface{}
+	Column string
+	Filter Filter
+}
+
+// Where is a single column condition.
+func Where(column string, op Op, value interface{}) Filter {
+	return Filter{
+		Column: column,
+		Op:     op,
+		Value:  value,
+	}
+}
+
+// And matches rows matching every filter.
+func And(filters ...Filter) Filter {
+	return Filter{And: filters}
+}
+
+// Or matches rows matching any filter.
+func Or(filters ...Filter) Filter {
+	return Filter{Or: filters}
+}
+
+// Between matches from <= column < to, either bound may be nil.
+func Between(column string, from, to interface{}) Filter {
+	var filters []Filter
+	if from != nil {
+		filters = append(filters, Where(column, OpGte, from))
+	}
+	if to != nil {
+		filters = append(filters, Where(column, OpLt, to))
+	}
+	return And(filters...)
+}
+
+// Match builds an equality filter from the non zero fields of a row
+// struct, invalid sql.Nullx fields are skipped. Array fields match
+// rows containing every element, jsonb fields rows containing the value.
+func Match(row interface{}) (Filter, error) {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	t, err := tableOf(v.Type())
+	if err != nil {
+		return Filter{}, err
+	}
+
+	var filters []Filter
+	for _, col := range t.columns {
+		field := v.Field(col.index)
+		if field.IsZero() {
+			continue
+		}
+
+		value := field.Interface()
+		contains := col.kind == kindArray || col.kind == kindJSON
+		if valuer, ok := value.(driver.Valuer); ok && !contains {
+			value, err = valuer.Value()
+			if err != nil {
+				return Filter{}, fmt.Errorf("%s value %w", col.name, err)
+			}
+			if value == nil {
+				continue
+			}
+		}
+
+		op := OpEq
+		if contains {
+			op = OpContains
+		}
+		filters = append(filters, Where(col.name, op, value))
+	}
+
+	return And(filters...), nil
+}
+
+// columnKind groups column types by the operators they support.
+type columnKind int
+
+const (
+	kindScalar columnKind = iota
+	kindText
+	kindArray
+	kindJSON
+)
+
+// String names k in errors.
+func (k columnKind) String() string {
+	switch k {
+	case kindText:
+		return "text"
+	case kindArray:
+		return "array"
+	case kindJSON:
+		return "jsonb"
+	}
+	return "scalar"
+}
+
+// jsonColumn is implemented by types stored as jsonb.
+type jsonColumn interface {
+	jsonb()
+}
+
+// jsonColumnType is the reflect type of jsonColumn.
+var jsonColumnType = reflect.TypeOf((*jsonColumn)(nil)).Elem()
+
+// column is a whitelisted column read from a db tag.
+type column struct {
+	name  string
+	index int
+	kind  columnKind
+}
+
+// table is the ordered column whitelist of a row struct.
+type table struct {
+	columns []column
+	byName  map[string]column
+}
+
+// tables caches tableOf results by type.
+var tables sync.Map
+
+// tableOf reads the db tagged columns of a row struct type.
+func tableOf(rt reflect.Type) (*table, error) {
+	if cached, ok := tables.Load(rt); ok {
+		return cached.(*table), nil
+	}
+	if rt.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %s is not a struct", ErrInvalidFilter, rt)
+	}
+
+	t := &table{byName: make(map[string]column)}
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name := strings.Split(f.Tag.Get("db"), ",")[0]
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+
+		col := column{
+			name:  name,
+			index: i,
+			kind:  kindOf(f.Type),
+		}
+		t.columns = append(t.columns, col)
+		t.byName[name] = col
+	}
+	tables.Store(rt, t)
+
+	return t, nil
+}
+
+// kindOf classifies a field type.
+func kindOf(ft reflect.Type) columnKind {
+	switch {
+	case ft.Implements(jsonColumnType):
+		return kindJSON
+	case ft == reflect.TypeOf(sql.NullString{}), ft.Kind() == reflect.String:
+		return kindText
+	case ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8:
+		return kindArray
+	}
+	return kindScalar
+}
+
+// names returns the quoted column list in struct order.
+func (t *table) names() string {
+	names := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		names[i] = pq.QuoteIdentifier(col.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// selectQuery builds a SELECT of row's columns from name filtered by f.
+// Output is deterministic, values are bound as positional arguments.
+func selectQuery(name string, row interface{}, f Filter) (string, []interface{}, error) {
+	t, err := tableOf(reflect.Indirect(reflect.ValueOf(row)).Type())
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", t.names(), pq.QuoteIdentifier(name))
+	where, args, err := t.where(f)
+	if err != nil {
+		return "", nil, err
+	}
+	if where != "" {
+		query += " WHERE " + where
+	}
+
+	return query, args, nil
+}
+
+// where renders f as a boolean expression, empty when f matches every row.
+func (t *table) where(f Filter) (string, []interface{}, error) {
+	b := &builder{table: t}
+	if isZero(f) {
+		return "", nil, nil
+	}
+	err := b.filter(f, true)
+	if err != nil {
+		return "", nil, err
+	}
+	return b.sb.String(), b.args, nil
+}
+
+// builder accumulates SQL text and arguments.
+type builder struct {
+	table *table
+	sb    strings.Builder
+	args  []interface{}
+}
+
+// bind adds an argument and returns its placeholder.
+func (b *builder) bind(value interface{}) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// filter renders one node, top level groups are not parenthesized.
+func (b *builder) filter(f Filter, top bool) error {
+	groups := 0
+	if f.Column != "" {
+		groups++
+	}
+	if !isZero(Filter{And: f.And}) {
+		groups++
+	}
+	if !isZero(Filter{Or: f.Or}) {
+		groups++
+	}
+	if groups != 1 {
+		return fmt.Errorf("%w: set exactly one of column, and, or", ErrInvalidFilter)
+	}
+
+	switch {
+	case f.Column != "":
+		return b.condition(f)
+	case !isZero(Filter{And: f.And}):
+		return b.group(f.And, " AND ", top)
+	}
+	return b.group(f.Or, " OR ", top)
+}
+
+// group joins child filters with sep, empty children are skipped.
+func (b *builder) group(children []Filter, sep string, top bool) error {
+	var filters []Filter
+	for _, child := range children {
+		if !isZero(child) {
+			filters = append(filters, child)
+		}
+	}
+	if len(filters) == 1 {
+		return b.filter(filters[0], top)
+	}
+
+	if !top {
+		b.sb.WriteString("(")
+	}
+	for i, child := range filters {
+		if i > 0 {
+			b.sb.WriteString(sep)
+		}
+		err := b.filter(child, false)
+		if err != nil {
+			return err
+		}
+	}
+	if !top {
+		b.sb.WriteString(")")
+	}
+
+	return nil
+}
+
+// condition renders a single column comparison.
+func (b *builder) condition(f Filter) error {
+	col, ok := b.table.byName[f.Column]
+	if !ok {
+		return fmt.Errorf("%w: unknown column %q", ErrInvalidFilter, f.Column)
+	}
+	name := pq.QuoteIdentifier(col.name)
+
+	switch f.Op {
+	case OpIsNull, OpNotNull:
+		fmt.Fprintf(&b.sb, "%s %s", name, f.Op)
+		return nil
+	}
+	if f.Value == nil {
+		return fmt.Errorf("%w: %s %s needs a value, use IS NULL", ErrInvalidFilter, col.name, f.Op)
+	}
+
+	switch f.Op {
+	case OpEq, OpNe, OpLt, OpLte, OpGt, OpGte:
+		if col.kind == kindArray || col.kind == kindJSON {
+			return fmt.Errorf("%w: %s on %s column %s", ErrInvalidFilter, f.Op, col.kind, col.name)
+		}
+		fmt.Fprintf(&b.sb, "%s %s %s", name, f.Op, b.bind(f.Value))
+	case OpLike:
+		if col.kind != kindText {
+			return fmt.Errorf("%w: LIKE on non text column %s", ErrInvalidFilter, col.name)
+		}
+		s, ok := f.Value.(string)
+		if !ok {
+			return fmt.Errorf("%w: LIKE %s needs a string", ErrInvalidFilter, col.name)
+		}
+		fmt.Fprintf(&b.sb, "%s LIKE %s", name, b.bind(s))
+	case OpIn:
+		if col.kind == kindArray || col.kind == kindJSON {
+			return fmt.Errorf("%w: IN on %s column %s", ErrInvalidFilter, col.kind, col.name)
+		}
+		if sub, ok := f.Value.(Subquery); ok {
+			return b.subquery(name, sub)
+		}
+		values := reflect.ValueOf(f.Value)
+		if values.Kind() != reflect.Slice || values.Len() == 0 {
+			return fmt.Errorf("%w: IN %s needs a non empty list", ErrInvalidFilter, col.name)
+		}
+		placeholders := make([]string, values.Len())
+		for i := range placeholders {
+			placeholders[i] = b.bind(values.Index(i).Interface())
+		}
+		fmt.Fprintf(&b.sb, "%s IN (%s)", name, strings.Join(placeholders, ", "))
+	case OpContains:
+		if col.kind == kindJSON {
+			return b.containsJSON(name, f.Value)
+		}
+		if col.kind != kindArray {
+			return fmt.Errorf("%w: @> on non array column %s", ErrInvalidFilter, col.name)
+		}
+		value := f.Value
+		if _, ok := value.(driver.Valuer); !ok {
+			value = pq.Array(value)
+		}
+		fmt.Fprintf(&b.sb, "%s @> %s", name, b.bind(value))
+	default:
+		return fmt.Errorf("%w: unsupported operator %q", ErrInvalidFilter, f.Op)
+	}
+
+	return nil
+}
+
+// subquery renders name IN a SELECT of sub.Column, its filter
+// checked against sub.Row. Soft deleted rows are never selected.
+func (b *builder) subquery(name string, sub Subquery) error {
+	t, err := tableOf(reflect.Indirect(reflect.ValueOf(sub.Row)).Type())
+	if err != nil {
+		return err
+	}
+	col, ok := t.byName[sub.Column]
+	if !ok {
+		return fmt.Errorf("%w: unknown column %q of %s", ErrInvalidFilter, sub.Column, sub.Table)
+	}
+
+	fmt.Fprintf(
+		&b.sb,
+		"%s IN (SELECT %s FROM %s",
+		name,
+		pq.QuoteIdentifier(col.name),
+		pq.QuoteIdentifier(sub.Table),
+	)
+	f := sub.Filter
+	if t.softDeletes() {
+		f = And(f, live())
+	}
+	if !isZero(f) {
+		outer := b.table
+		b.table = t
+		b.sb.WriteString(" WHERE ")
+		err = b.filter(f, true)
+		b.table = outer
+		if err != nil {
+			return err
+		}
+	}
+	b.sb.WriteString(")")
+	return nil
+}
+
+// containsJSON renders a jsonb containment, value is marshaled to JSON
+// unless it is a driver.Valuer.
+func (b *builder) containsJSON(name string, value interface{}) error {
+	if _, ok := value.(driver.Valuer); !ok {
+		doc, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("%w: %s @> value %v", ErrInvalidFilter, name, err)
+		}
+		value = string(doc)
+	}
+	fmt.Fprintf(&b.sb, "%s @> %s::jsonb", name, b.bind(value))
+	return nil
+}
+
+// isZero reports whether f matches every row, either empty or
+// built only from empty groups.
+func isZero(f Filter) bool {
+	if f.Column != "" {
+		return false
+	}
+	for _, child := range f.And {
+		if !isZero(child) {
+			return false
+		}
+	}
+	for _, child := range f.Or {
+		if !isZero(child) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata golden files")
+
+// taggedRow has an array column, which no stored table has.
+type taggedRow struct {
+	ID   string   `db:"id"`
+	Tags []string `db:"tags"`
+}
+
+// golden compares got with testdata/name.golden, rewriting it with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if got != string(want) {
+		t.Fatalf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// render formats a query and its arguments for a golden file.
+func render(query string, args []interface{}) string {
+	var b strings.Builder
+	b.WriteString(query)
+	b.WriteString("\n")
+	for i, arg := range args {
+		fmt.Fprintf(&b, "$%d %T %v\n", i+1, arg, arg)
+	}
+	return b.String()
+}
+
+func TestSelectQueryGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		row   interface{}
+		f     Filter
+	}{
+		{"empty", "person", Person{}, Filter{}},
+		{"empty_groups", "person", Person{}, And(Or(), And())},
+		{"eq", "person", Person{}, Where("email", OpEq, "ada@example.com")},
+		{"ne", "person", Person{}, Where("last_name", OpNe, "Lovelace")},
+		{"lt", "person", Person{}, Where("timestamp", OpLt, 10)},
+		{"lte", "person", Person{}, Where("timestamp", OpLte, 10)},
+		{"gt", "person", Person{}, Where("timestamp", OpGt, 10)},
+		{"gte", "person", Person{}, Where("timestamp", OpGte, 10)},
+		{"like", "person", Person{}, Where("first_name", OpLike, "Ad%")},
+		{"is_null", "place", Place{}, Where("city", OpIsNull, nil)},
+		{"not_null", "place", Place{}, Where("city", OpNotNull, nil)},
+		{"in", "person", Person{}, Where("id", OpIn, []string{"a", "b", "c"})},
+		{"in_subquery", "relationship", Relationship{}, Where("place_id", OpIn, Subquery{
+			Table:  "place",
+			Row:    Place{},
+			Column: "id",
+			Filter: Where("country", OpEq, "NZ"),
+		})},
+		{"between", "person", Person{}, Between("timestamp", 10, 20)},
+		{"between_open", "person", Person{}, Between("timestamp", nil, 20)},
+		{"contains_json", "place", Place{}, Where("comments", OpContains, []map[string]string{{"author": "ada"}})},
+		{"contains_array", "tagged", taggedRow{}, Where("tags", OpContains, []string{"a", "b"})},
+		{"nested", "person", Person{}, And(
+			Where("last_name", OpEq, "Lovelace"),
+			Or(
+				Where("first_name", OpLike, "A%"),
+				And(Where("timestamp", OpGte, 10), Where("email", OpNotNull, nil)),
+			),
+			Or(Where("id", OpIn, []string{"x"})),
+		)},
+		{"match", "place", Place{}, mustMatch(t, Place{Country: "NZ", TelCode: 64})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := selectQuery(tt.table, tt.row, tt.f)
+			if err != nil {
+				t.Fatalf("selectQuery: %v", err)
+			}
+			golden(t, "select_"+tt.name, render(query, args))
+		})
+	}
+}
+
+func TestSelectQueryRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		row  interface{}
+		f    Filter
+	}{
+		{"unknown_column", Person{}, Where("password", OpEq, "x")},
+		{"injected_column", Person{}, Where(`id" = '' OR "1`, OpEq, "x")},
+		{"unknown_nested_column", Person{}, Or(Where("id", OpEq, "a"), And(Where("nope", OpIsNull, nil)))},
+		{"unknown_subquery_column", Relationship{}, Where("place_id", OpIn, Subquery{Table: "place", Row: Place{}, Column: "secret"})},
+		{"unknown_subquery_filter", Relationship{}, Where("place_id", OpIn, Subquery{Table: "place", Row: Place{}, Column: "id", Filter: Where("nope", OpEq, 1)})},
+		{"unsupported_op", Person{}, Where("id", Op("~"), "a")},
+		{"missing_value", Person{}, Where("id", OpEq, nil)},
+		{"like_non_text", Person{}, Where("timestamp", OpLike, "1%")},
+		{"in_empty", Person{}, Where("id", OpIn, []string{})},
+		{"in_scalar", Person{}, Where("id", OpIn, "a")},
+		{"compare_json", Place{}, Where("comments", OpEq, "[]")},
+		{"contains_scalar", Person{}, Where("id", OpContains, "a")},
+		{"column_and_group", Person{}, Filter{Column: "id", Op: OpEq, Value: "a", And: []Filter{Where("id", OpEq, "b")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _, err := selectQuery("t", tt.row, tt.f)
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Fatalf("selectQuery: got %q, %v, want %v", query, err, ErrInvalidFilter)
+			}
+		})
+	}
+}
+
+// mustMatch builds a Match filter or fails the test.
+func mustMatch(t *testing.T, row interface{}) Filter {
+	t.Helper()
+	f, err := Match(row)
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	return f
+}
@@ -0,0 +1,462 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxFingerprints bounds the histograms kept per database, later
+	// fingerprints are counted as otherFingerprint.
+	maxFingerprints  = 500
+	otherFingerprint = "other"
+	// explainTimeout bounds capturing one plan.
+	explainTimeout = 30 * time.Second
+)
+
+// latencyBuckets are the histogram upper bounds in seconds.
+var latencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// defaultInstrument are the instrumentation defaults.
+var defaultInstrument = InstrumentConfig{
+	SlowThreshold:   200 * time.Millisecond,
+	ExplainInterval: time.Minute,
+}
+
+// InstrumentConfig defines query instrumentation under postgres.instrument.
+type InstrumentConfig struct {
+	// SlowThreshold logs queries running longer at warn, zero for none.
+	SlowThreshold time.Duration `yaml:"slow_threshold"`
+	// Env is the environment the app runs in.
+	Env string `yaml:"env"`
+	// ExplainEnvs are the environments whose slow selects are run
+	// again with EXPLAIN ANALYZE, logging the plan.
+	ExplainEnvs []string `yaml:"explain_envs"`
+	// ExplainInterval is the least time between plans of a fingerprint.
+	ExplainInterval time.Duration `yaml:"explain_interval"`
+}
+
+var (
+	commentPattern = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/`)
+	stringPattern  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	paramPattern   = regexp.MustCompile(`\$\d+`)
+	numberPattern  = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	listPattern    = regexp.MustCompile(`\?(?:\s*,\s*\?)+`)
+	spacePattern   = regexp.MustCompile(`\s+`)
+	// selectPattern and sideEffectPattern find selects that are safe
+	// to run again for their plan.
+	selectPattern     = regexp.MustCompile(`(?i)^SELECT\b`)
+	sideEffectPattern = regexp.MustCompile(`(?i)\bFOR\s+(?:NO\s+KEY\s+)?(?:UPDATE|SHARE|KEY\s+SHARE)\b|\b(?:pg_advisory\w*|pg_notify|nextval|setval)\s*\(`)
+)
+
+// fingerprint normalises query so queries differing only in values,
+// comments and spacing share it.
+func fingerprint(query string) string {
+	fp := commentPattern.ReplaceAllString(query, " ")
+	fp = stringPattern.ReplaceAllString(fp, "?")
+	fp = paramPattern.ReplaceAllString(fp, "?")
+	fp = numberPattern.ReplaceAllString(fp, "?")
+	fp = listPattern.ReplaceAllString(fp, "?, ...")
+	return strings.TrimSpace(spacePattern.ReplaceAllString(fp, " "))
+}
+
+// QueryStats keeps query latency histograms per database and
+// fingerprint, served in the Prometheus text format.
+type QueryStats struct {
+	mu      sync.Mutex
+	queries map[queryKey]*queryHistogram
+	// fingerprints counts the fingerprints kept per database.
+	fingerprints map[string]int
+}
+
+// queryKey identifies a histogram.
+type queryKey struct {
+	db    string
+	query string
+}
+
+// queryHistogram counts query latencies per bucket, not cumulatively.
+type queryHistogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+	errors  uint64
+}
+
+// NewQueryStats is the QueryStats constructor.
+func NewQueryStats() *QueryStats {
+	return &QueryStats{
+		queries:      make(map[queryKey]*queryHistogram),
+		fingerprints: make(map[string]int),
+	}
+}
+
+// observe counts one query.
+func (s *QueryStats) observe(db, fp string, d time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := queryKey{db: db, query: fp}
+	h, ok := s.queries[key]
+	if !ok {
+		if s.fingerprints[db] >= maxFingerprints {
+			key.query = otherFingerprint
+			h, ok = s.queries[key]
+		}
+		if !ok {
+			h = &queryHistogram{buckets: make([]uint64, len(latencyBuckets))}
+			s.queries[key] = h
+			s.fingerprints[db]++
+		}
+	}
+
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, seconds)
+	if i < len(h.buckets) {
+		h.buckets[i]++
+	}
+	h.count++
+	h.sum += seconds
+	if failed {
+		h.errors++
+	}
+}
+
+// labelEscaper escapes Prometheus label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ServeHTTP writes the histograms in the Prometheus text format.
+func (s *QueryStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	keys := make([]queryKey, 0, len(s.queries))
+	for key := range s.queries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].db != keys[j].db {
+			return keys[i].db < keys[j].db
+		}
+		return keys[i].query < keys[j].query
+	})
+
+	var b bytes.Buffer
+	b.WriteString("# HELP postgres_query_duration_seconds Query latency by database and fingerprint.\n")
+	b.WriteString("# TYPE postgres_query_duration_seconds histogram\n")
+	for _, key := range keys {
+		h := s.queries[key]
+		labels := fmt.Sprintf(`db="%s",query="%s"`, labelEscaper.Replace(key.db), labelEscaper.Replace(key.query))
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(&b, "postgres_query_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "postgres_query_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&b, "postgres_query_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "postgres_query_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+	b.WriteString("# HELP postgres_query_errors_total Failed queries by database and fingerprint.\n")
+	b.WriteString("# TYPE postgres_query_errors_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "postgres_query_errors_total{db=\"%s\",query=\"%s\"} %d\n",
+			labelEscaper.Replace(key.db), labelEscaper.Replace(key.query), s.queries[key].errors)
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// openDB configures an instrumented pool for cfg, labelled db in logs
+// and histograms.
+func openDB(cfg Config, db string, stats *QueryStats, log *zap.Logger) (*sqlx.DB, error) {
+	icfg := cfg.Instrument
+	if icfg.SlowThreshold < 0 {
+		return nil, fmt.Errorf("postgres instrument slow_threshold must not be negative")
+	}
+	explain := icfg.Env != "" && slices.Contains(icfg.ExplainEnvs, icfg.Env)
+	if explain && icfg.ExplainInterval <= 0 {
+		return nil, fmt.Errorf("postgres instrument explain_interval must be positive")
+	}
+
+	connector, err := pq.NewConnector(cfg.dsn())
+	if err != nil {
+		return nil, fmt.Errorf("pq NewConnector %w", err)
+	}
+	in := &instrument{
+		cfg:       icfg,
+		db:        db,
+		explain:   explain,
+		stats:     stats,
+		log:       log.Named("query").With(zap.String("db", db)),
+		connector: connector,
+		explained: make(map[string]time.Time),
+	}
+
+	pool := sqlx.NewDb(sql.OpenDB(instrumentedConnector{Connector: connector, in: in}), "postgres")
+	pool.SetMaxOpenConns(cfg.Pool.MaxOpen)
+	if cfg.Pool.MaxIdle > 0 {
+		pool.SetMaxIdleConns(cfg.Pool.MaxIdle)
+	}
+	pool.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	pool.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+	return pool, nil
+}
+
+// instrument times, logs and counts the queries of one pool.
+type instrument struct {
+	cfg     InstrumentConfig
+	db      string
+	explain bool
+	stats   *QueryStats
+	log     *zap.Logger
+	// connector opens uninstrumented connections for plans.
+	connector driver.Connector
+
+	mu         sync.Mutex
+	explaining bool
+	explained  map[string]time.Time
+}
+
+// record logs and counts a finished query, capturing the plan of slow
+// ones when enabled.
+func (in *instrument) record(query string, args []driver.NamedValue, start time.Time, rows int64, err error) {
+	d := time.Since(start)
+	fp := fingerprint(query)
+	in.stats.observe(in.db, fp, d, err != nil)
+
+	fields := []zap.Field{
+		zap.String("query", fp),
+		zap.Duration("duration", d),
+		zap.Int64("rows", rows),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	if in.cfg.SlowThreshold == 0 || d <= in.cfg.SlowThreshold {
+		in.log.Debug("query", fields...)
+		return
+	}
+	in.log.Warn("slow query", fields...)
+	if in.explain && err == nil {
+		in.explainLater(query, fp, args)
+	}
+}
+
+// explainLater logs the plan of a slow select, run again on its own
+// connection. One plan is captured at a time, and a fingerprint at most
+// once per ExplainInterval.
+func (in *instrument) explainLater(query, fp string, args []driver.NamedValue) {
+	if !selectPattern.MatchString(fp) || sideEffectPattern.MatchString(fp) {
+		return
+	}
+
+	in.mu.Lock()
+	last, ok := in.explained[fp]
+	if in.explaining || (ok && time.Since(last) < in.cfg.ExplainInterval) {
+		in.mu.Unlock()
+		return
+	}
+	in.explaining = true
+	in.explained[fp] = time.Now()
+	in.mu.Unlock()
+
+	// The caller may reuse byte slices once the query returns.
+	args = slices.Clone(args)
+	for i, arg := range args {
+		if b, ok := arg.Value.([]byte); ok {
+			args[i].Value = slices.Clone(b)
+		}
+	}
+
+	go func() {
+		defer func() {
+			in.mu.Lock()
+			in.explaining = false
+			in.mu.Unlock()
+		}()
+
+		plan, err := in.plan(query, args)
+		if err != nil {
+			// Queries on temp tables cannot be planned elsewhere.
+			in.log.Debug("explain query", zap.String("query", fp), zap.Error(err))
+			return
+		}
+		in.log.Info("query plan", zap.String("query", fp), zap.String("plan", plan))
+	}()
+}
+
+// plan runs query with EXPLAIN ANALYZE and returns the plan text.
+func (in *instrument) plan(query string, args []driver.NamedValue) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+
+	conn, err := in.connector.Connect(ctx)
+	if err != nil {
+		return "", fmt.Errorf("connect %w", err)
+	}
+	defer conn.Close()
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return "", fmt.Errorf("connection %T cannot query", conn)
+	}
+
+	rows, err := queryer.QueryContext(ctx, "EXPLAIN (ANALYZE, BUFFERS) "+query, args)
+	if err != nil {
+		return "", fmt.Errorf("explain %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	dest := make([]driver.Value, len(rows.Columns()))
+	for {
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("explain rows %w", err)
+		}
+		lines = append(lines, fmt.Sprint(dest[0]))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// instrumentedConnector opens instrumented lib/pq connections.
+type instrumentedConnector struct {
+	driver.Connector
+	in *instrument
+}
+
+// Connect .
+func (c instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc, ok := conn.(pqConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("instrument unsupported connection %T", conn)
+	}
+	return &instrumentedConn{pqConn: pc, in: c.in}, nil
+}
+
+// pqConn is the driver interfaces of a lib/pq connection.
+type pqConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.QueryerContext
+	driver.ExecerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// instrumentedConn records queries and execs. Prepared statements,
+// only used to COPY, are not recorded.
+type instrumentedConn struct {
+	pqConn
+	in *instrument
+}
+
+// QueryContext records the query once its rows are closed.
+func (c *instrumentedConn) QueryContext(
+	ctx context.Context,
+	query string,
+	args []driver.NamedValue,
+) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.pqConn.QueryContext(ctx, query, args)
+	if err != nil {
+		c.in.record(query, args, start, 0, err)
+		return nil, err
+	}
+	pr, ok := rows.(pqRows)
+	if !ok {
+		c.in.record(query, args, start, 0, nil)
+		return rows, nil
+	}
+	return &instrumentedRows{pqRows: pr, in: c.in, query: query, args: args, start: start}, nil
+}
+
+// ExecContext records the exec and the rows it affected.
+func (c *instrumentedConn) ExecContext(
+	ctx context.Context,
+	query string,
+	args []driver.NamedValue,
+) (driver.Result, error) {
+	start := time.Now()
+	res, err := c.pqConn.ExecContext(ctx, query, args)
+	var rows int64
+	if err == nil {
+		rows, _ = res.RowsAffected()
+	}
+	c.in.record(query, args, start, rows, err)
+	return res, err
+}
+
+// pqRows is the driver interfaces of lib/pq rows.
+type pqRows interface {
+	driver.Rows
+	driver.RowsNextResultSet
+	driver.RowsColumnTypeScanType
+	driver.RowsColumnTypeDatabaseTypeName
+	driver.RowsColumnTypeLength
+	driver.RowsColumnTypePrecisionScale
+}
+
+// instrumentedRows counts rows read and records the query as closed,
+// so its duration includes reading them.
+type instrumentedRows struct {
+	pqRows
+	in     *instrument
+	query  string
+	args   []driver.NamedValue
+	start  time.Time
+	rows   int64
+	err    error
+	closed bool
+}
+
+// Next .
+func (r *instrumentedRows) Next(dest []driver.Value) error {
+	err := r.pqRows.Next(dest)
+	switch {
+	case err == nil:
+		r.rows++
+	case err != io.EOF:
+		r.err = err
+	}
+	return err
+}
+
+// Close .
+func (r *instrumentedRows) Close() error {
+	err := r.pqRows.Close()
+	if !r.closed {
+		r.closed = true
+		if r.err == nil {
+			r.err = err
+		}
+		r.in.record(r.query, r.args, r.start, r.rows, r.err)
+	}
+	return err
+}
@@ -0,0 +1,273 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	// changesChannel is notified by the change triggers.
+	changesChannel = "entity_changes"
+	// listenPing checks an idle listener connection is still alive.
+	listenPing = 90 * time.Second
+)
+
+// ChangeResync is the operation of the change sent after the feed
+// reconnects or a subscription drops changes, when changes may have
+// been missed.
+const ChangeResync Operation = "resync"
+
+// Change is a committed change to a person or place. Soft deletes are
+// reported as AuditDelete.
+type Change struct {
+	// Entity is the table name, empty on resync.
+	Entity    string    `json:"entity"`
+	ID        string    `json:"id"`
+	Operation Operation `json:"operation"`
+	Version   int64     `json:"version"`
+}
+
+// ListenConfig defines change feed settings under postgres.listen.
+type ListenConfig struct {
+	// MinReconnect and MaxReconnect bound the wait between reconnects.
+	MinReconnect time.Duration `yaml:"min_reconnect"`
+	MaxReconnect time.Duration `yaml:"max_reconnect"`
+	// Buffer is the changes buffered per subscription before dropping.
+	Buffer int `yaml:"buffer"`
+}
+
+// ChangeSubscription delivers changes until closed. Changes are
+// dropped when C is not drained fast enough, followed by a resync.
+type ChangeSubscription struct {
+	C <-chan Change
+
+	ch       chan Change
+	entities map[string]bool
+	feed     *Feed
+	once     sync.Once
+}
+
+// Close stops delivery and closes C.
+func (s *ChangeSubscription) Close() {
+	s.once.Do(func() {
+		s.feed.remove(s)
+	})
+}
+
+// Feed listens for change notifications on a dedicated connection and
+// fans them out to subscriptions.
+type Feed struct {
+	mu       sync.Mutex
+	cfg      ListenConfig
+	log      *zap.Logger
+	listener *pq.Listener
+	subs     map[*ChangeSubscription]struct{}
+	closed   bool
+}
+
+// FeedParams defines NewFeed requirements.
+type FeedParams struct {
+	fx.In
+
+	Cfg config.Provider
+	Lc  fx.Lifecycle
+	Log *zap.Logger
+}
+
+// NewFeed is the Feed constructor. It listens from app start to stop,
+// reconnecting with backoff.
+func NewFeed(p FeedParams) (*Feed, error) {
+	pcfg := Config{
+		Listen: ListenConfig{
+			MinReconnect: time.Second,
+			MaxReconnect: time.Minute,
+			Buffer:       100,
+		},
+	}
+	err := p.Cfg.Get("postgres").Populate(&pcfg)
+	if err != nil {
+		return nil, fmt.Errorf("postgres config %w", err)
+	}
+	if pcfg.Listen.MinReconnect <= 0 || pcfg.Listen.MaxReconnect < pcfg.Listen.MinReconnect {
+		return nil, fmt.Errorf("postgres listen reconnect bounds are invalid")
+	}
+	if pcfg.Listen.Buffer <= 0 {
+		return nil, fmt.Errorf("postgres listen buffer must be positive")
+	}
+
+	f := &Feed{
+		cfg:  pcfg.Listen,
+		log:  p.Log.Named("feed"),
+		subs: make(map[*ChangeSubscription]struct{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.Lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			// The listener connects in the background as it is created.
+			f.listener = pq.NewListener(pcfg.dsn(), f.cfg.MinReconnect, f.cfg.MaxReconnect, f.event)
+			go func() {
+				defer close(done)
+				f.run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			<-done
+			f.close()
+			return f.listener.Close()
+		},
+	})
+
+	return f, nil
+}
+
+// Subscribe receives changes to entities, table names, or to every
+// entity when none are given, until closed or the app stops.
+func (f *Feed) Subscribe(entities ...string) (*ChangeSubscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil, fmt.Errorf("subscribe changes: feed closed")
+	}
+
+	// One slot beyond the buffer is kept for the resync after drops.
+	ch := make(chan Change, f.cfg.Buffer+1)
+	sub := &ChangeSubscription{
+		C:    ch,
+		ch:   ch,
+		feed: f,
+	}
+	if len(entities) > 0 {
+		sub.entities = make(map[string]bool, len(entities))
+		for _, entity := range entities {
+			sub.entities[entity] = true
+		}
+	}
+	f.subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// remove unregisters a subscription and closes its channel.
+func (f *Feed) remove(sub *ChangeSubscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subs[sub]; !ok {
+		return
+	}
+	delete(f.subs, sub)
+	close(sub.ch)
+}
+
+// deliver hands c to every matching subscription without blocking.
+// Resyncs go to every subscription. A subscription with a full buffer
+// drops c and gets a resync after the changes it has buffered.
+func (f *Feed) deliver(c Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subs {
+		if c.Operation != ChangeResync && sub.entities != nil && !sub.entities[c.Entity] {
+			continue
+		}
+		if c.Operation == ChangeResync || len(sub.ch) < f.cfg.Buffer {
+			select {
+			case sub.ch <- c:
+				continue
+			default:
+			}
+		}
+		f.log.Warn("change buffer full, dropping change",
+			zap.String("entity", c.Entity),
+			zap.String("id", c.ID),
+		)
+		// A full channel still holds an unread resync, which covers c.
+		select {
+		case sub.ch <- Change{Operation: ChangeResync}:
+		default:
+		}
+	}
+}
+
+// close ends every subscription and rejects new ones.
+func (f *Feed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for sub := range f.subs {
+		close(sub.ch)
+		delete(f.subs, sub)
+	}
+}
+
+// run listens and delivers notifications until ctx is canceled. The
+// listener reconnects on its own and signals it with a nil
+// notification, idle connections are pinged so half open ones are
+// noticed.
+func (f *Feed) run(ctx context.Context) {
+	listened := make(chan error, 1)
+	go func() {
+		// Blocks until connected, listening again after reconnects.
+		listened <- f.listener.Listen(changesChannel)
+	}()
+
+	ping := time.NewTicker(listenPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-listened:
+			if err != nil {
+				f.log.Error("listen changes", zap.Error(err))
+			}
+		case n := <-f.listener.Notify:
+			if n == nil {
+				f.deliver(Change{Operation: ChangeResync})
+				continue
+			}
+			var c Change
+			err := json.Unmarshal([]byte(n.Extra), &c)
+			if err != nil {
+				f.log.Error("decode change", zap.String("payload", n.Extra), zap.Error(err))
+				continue
+			}
+			f.deliver(c)
+		case <-ping.C:
+			go func() {
+				err := f.listener.Ping()
+				if err != nil {
+					f.log.Warn("listener ping", zap.Error(err))
+				}
+			}()
+		}
+	}
+}
+
+// event logs the listener's connection state.
+func (f *Feed) event(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventConnected:
+		f.log.Info("listening for changes")
+	case pq.ListenerEventDisconnected:
+		f.log.Warn("change listener disconnected", zap.Error(err))
+	case pq.ListenerEventReconnected:
+		f.log.Info("change listener reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		f.log.Warn("change listener connect failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFeedResyncsAfterDrops(t *testing.T) {
+	f := &Feed{
+		cfg:  ListenConfig{Buffer: 2},
+		log:  zap.NewNop(),
+		subs: make(map[*ChangeSubscription]struct{}),
+	}
+	sub, err := f.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		f.deliver(Change{Entity: "person", ID: id, Operation: "update"})
+	}
+	want := []Change{
+		{Entity: "person", ID: "a", Operation: "update"},
+		{Entity: "person", ID: "b", Operation: "update"},
+		{Operation: ChangeResync},
+	}
+	for _, w := range want {
+		if got := <-sub.C; got != w {
+			t.Fatalf("received %+v, want %+v", got, w)
+		}
+	}
+
+	// Once drained, changes are delivered again.
+	f.deliver(Change{Entity: "person", ID: "e", Operation: "update"})
+	if got := <-sub.C; got.ID != "e" {
+		t.Fatalf("received %+v after draining, want e", got)
+	}
+	select {
+	case got := <-sub.C:
+		t.Fatalf("received %+v, want nothing more", got)
+	default:
+	}
+}
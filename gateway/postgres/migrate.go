@@ -0,0 +1,433 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// migrationLockKey is the advisory lock held while migrating so
+// concurrent migrators wait for each other.
+const migrationLockKey int64 = 0x6678_6d69_6772_6174
+
+// migrationsTable records applied migrations.
+const migrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version bigint PRIMARY KEY,
+    name text NOT NULL,
+    checksum text NOT NULL,
+    applied_at timestamptz NOT NULL DEFAULT now()
+)`
+
+// migrationFiles holds numbered up and down SQL files,
+// named like 0001_init.up.sql and 0001_init.down.sql.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFile matches migration file names.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrChecksumMismatch is returned when an applied migration's file changed.
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// Migration is a numbered schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// Checksum is the sha256 of Up, recorded when applied.
+	Checksum string
+}
+
+// MigrationStatus reports whether a migration is applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when the file changed since it was applied.
+	Modified bool
+	// Missing is set when an applied migration has no file.
+	Missing bool
+}
+
+// appliedMigration is a schema_migrations row.
+type appliedMigration struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Migrator applies and rolls back embedded migrations.
+type Migrator struct {
+	db         *sqlx.DB
+	log        *zap.Logger
+	migrations []Migration
+}
+
+// MigratorParams defines constructor requirements.
+type MigratorParams struct {
+	fx.In
+
+	DB  *sqlx.DB
+	Log *zap.Logger
+}
+
+// NewMigrator is the Migrator constructor.
+func NewMigrator(p MigratorParams) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("load migrations %w", err)
+	}
+
+	return &Migrator{
+		db:         p.DB,
+		log:        p.Log,
+		migrations: migrations,
+	}, nil
+}
+
+// MigrateOnStart applies pending migrations as the app starts
+// when postgres.migrate_on_start is set.
+func MigrateOnStart(lc fx.Lifecycle, cfg config.Provider, m *Migrator) error {
+	var pcfg Config
+	err := cfg.Get("postgres").Populate(&pcfg)
+	if err != nil {
+		return fmt.Errorf("postgres config %w", err)
+	}
+	if !pcfg.MigrateOnStart {
+		return nil
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: m.Up,
+	})
+
+	return nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.latest())
+}
+
+// Down rolls back the newest steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := m.verified(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			err := m.apply(ctx, conn, mig, false)
+			if err != nil {
+				return err
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// To migrates up or down until exactly the migrations at or below
+// version are applied. Version 0 rolls back everything.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	return m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := m.verified(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Roll back newest first, then apply oldest first.
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok || mig.Version <= version {
+				continue
+			}
+			err := m.apply(ctx, conn, mig, false)
+			if err != nil {
+				return err
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok || mig.Version > version {
+				continue
+			}
+			err := m.apply(ctx, conn, mig, true)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Status lists every known or applied migration by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sqlx.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			row, ok := applied[mig.Version]
+			statuses = append(statuses, MigrationStatus{
+				Migration: mig,
+				Applied:   ok,
+				AppliedAt: row.AppliedAt,
+				Modified:  ok && row.Checksum != mig.Checksum,
+			})
+			delete(applied, mig.Version)
+		}
+		for _, row := range applied {
+			statuses = append(statuses, MigrationStatus{
+				Migration: Migration{
+					Version:  row.Version,
+					Name:     row.Name,
+					Checksum: row.Checksum,
+				},
+				Applied:   true,
+				AppliedAt: row.AppliedAt,
+				Missing:   true,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("Connx %w", err)
+	}
+	defer conn.Close()
+
+	// Session level lock, released explicitly or when the connection closes.
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+	if err != nil {
+		return fmt.Errorf("advisory lock %w", err)
+	}
+	defer func() {
+		_, err := conn.ExecContext(
+			context.Background(),
+			"SELECT pg_advisory_unlock($1)",
+			migrationLockKey,
+		)
+		if err != nil {
+			m.log.Error("advisory unlock", zap.Error(err))
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, migrationsTable)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations %w", err)
+	}
+
+	return fn(conn)
+}
+
+// applied returns schema_migrations rows by version.
+func (m *Migrator) applied(ctx context.Context, conn *sqlx.Conn) (map[int64]appliedMigration, error) {
+	var rows []appliedMigration
+	err := sqlx.SelectContext(
+		ctx,
+		conn,
+		&rows,
+		"SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select schema_migrations %w", err)
+	}
+
+	applied := make(map[int64]appliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// verified returns applied migrations after checking each still has
+// an unchanged file.
+func (m *Migrator) verified(ctx context.Context, conn *sqlx.Conn) (map[int64]appliedMigration, error) {
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for version, row := range applied {
+		mig := m.find(version)
+		if mig == nil {
+			return nil, fmt.Errorf("applied migration %d_%s has no file", version, row.Name)
+		}
+		if mig.Checksum != row.Checksum {
+			return nil, fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, version, mig.Name)
+		}
+	}
+
+	return applied, nil
+}
+
+// apply runs one migration and records it in a single transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sqlx.Conn, mig Migration, up bool) error {
+	direction, script := "down", mig.Down
+	if up {
+		direction, script = "up", mig.Up
+	}
+	start := time.Now()
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BeginTxx %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		return fmt.Errorf("migrate %s %d_%s %w", direction, mig.Version, mig.Name, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(
+			ctx,
+			"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+			mig.Version,
+			mig.Name,
+			mig.Checksum,
+		)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("record migration %d %w", mig.Version, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Commit %w", err)
+	}
+
+	m.log.Info("migrated",
+		zap.String("direction", direction),
+		zap.Int64("version", mig.Version),
+		zap.String("name", mig.Name),
+		zap.Duration("took", time.Since(start)),
+	)
+
+	return nil
+}
+
+// find returns the migration with version, nil when unknown.
+func (m *Migrator) find(version int64) *Migration {
+	i := sort.Search(len(m.migrations), func(i int) bool {
+		return m.migrations[i].Version >= version
+	})
+	if i < len(m.migrations) && m.migrations[i].Version == version {
+		return &m.migrations[i]
+	}
+	return nil
+}
+
+// latest returns the newest migration version, 0 when there are none.
+func (m *Migrator) latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// loadMigrations reads and pairs the migration files in dir.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version %s", entry.Name())
+		}
+
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{
+				Version: version,
+				Name:    match[2],
+			}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has names %s and %s", version, mig.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(script)
+			mig.Up = string(script)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs up and down files", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
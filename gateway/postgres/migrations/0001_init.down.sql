@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS place;
+DROP TABLE IF EXISTS person;
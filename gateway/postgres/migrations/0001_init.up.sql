@@ -1,7 +1,5 @@
-package postgres
-
-// schema intial DB setup, define tables.
-var schema = `
+-- Tables previously created by InitializeDB, kept idempotent for
+-- databases set up before migrations existed.
 CREATE TABLE IF NOT EXISTS person (
     id text unique NOT NULL,
     first_name text,
@@ -17,4 +15,4 @@ CREATE TABLE IF NOT EXISTS place (
     comments text[] NULL,
     telcode integer,
     timestamp int
-)`
+);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS place_timestamp_id_idx;
+DROP INDEX IF EXISTS place_telcode_id_idx;
+DROP INDEX IF EXISTS place_country_id_idx;
+
+DROP INDEX IF EXISTS person_timestamp_id_idx;
+DROP INDEX IF EXISTS person_email_id_idx;
+DROP INDEX IF EXISTS person_last_name_id_idx;
+DROP INDEX IF EXISTS person_first_name_id_idx;
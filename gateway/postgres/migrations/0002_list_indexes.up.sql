@@ -0,0 +1,10 @@
+-- Keyset pagination seeks on (sort column, id), one index per
+-- sortable column. id is already indexed by its unique constraint.
+CREATE INDEX IF NOT EXISTS person_first_name_id_idx ON person (first_name, id);
+CREATE INDEX IF NOT EXISTS person_last_name_id_idx ON person (last_name, id);
+CREATE INDEX IF NOT EXISTS person_email_id_idx ON person (email, id);
+CREATE INDEX IF NOT EXISTS person_timestamp_id_idx ON person (timestamp, id);
+
+CREATE INDEX IF NOT EXISTS place_country_id_idx ON place (country, id);
+CREATE INDEX IF NOT EXISTS place_telcode_id_idx ON place (telcode, id);
+CREATE INDEX IF NOT EXISTS place_timestamp_id_idx ON place (timestamp, id);
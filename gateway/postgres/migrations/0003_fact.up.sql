@@ -0,0 +1,8 @@
+-- Cat facts, optionally shared by a person about a place.
+CREATE TABLE fact (
+    id text PRIMARY KEY,
+    fact text NOT NULL,
+    person_id text NULL REFERENCES person (id) ON DELETE SET NULL,
+    place_id text NULL REFERENCES place (id) ON DELETE SET NULL,
+    timestamp bigint
+);
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS place_comments_idx;
+
+-- Comments with fields go back to their JSON object, others to their
+-- text. Authors, ids and creation times are lost.
+ALTER TABLE place ADD COLUMN comments_text text[];
+
+UPDATE place SET comments_text = (
+    SELECT coalesce(array_agg(
+        CASE WHEN c ? 'fields' THEN (c->'fields')::text ELSE c->>'text' END
+        ORDER BY n
+    ), '{}')
+    FROM jsonb_array_elements(place.comments) WITH ORDINALITY AS e (c, n)
+);
+
+ALTER TABLE place DROP COLUMN comments;
+ALTER TABLE place RENAME COLUMN comments_text TO comments;
@@ -0,0 +1,45 @@
+-- Place comments move from text[] of JSON strings to a jsonb array of
+-- comment objects. Strings holding a flat JSON object become the
+-- comment fields, any other string becomes the comment text. Their
+-- author is recorded as legacy.
+CREATE FUNCTION pg_temp.place_comment(s text, created_at bigint) RETURNS jsonb AS $$
+DECLARE
+    doc jsonb;
+    fields jsonb;
+BEGIN
+    BEGIN
+        doc := s::jsonb;
+    EXCEPTION WHEN invalid_text_representation THEN
+        doc := NULL;
+    END;
+
+    IF jsonb_typeof(doc) = 'object' AND NOT EXISTS (
+        SELECT 1 FROM jsonb_each(doc) AS e WHERE jsonb_typeof(e.value) <> 'string'
+    ) THEN
+        fields := doc;
+    END IF;
+
+    RETURN jsonb_strip_nulls(jsonb_build_object(
+        'id', md5(random()::text || clock_timestamp()::text)::uuid,
+        'author', 'legacy',
+        'text', CASE WHEN fields IS NULL THEN s END,
+        'fields', fields,
+        'created_at', coalesce(created_at, 0)
+    ));
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE place ADD COLUMN comments_jsonb jsonb;
+
+UPDATE place SET comments_jsonb = (
+    SELECT coalesce(jsonb_agg(pg_temp.place_comment(c, place.timestamp) ORDER BY n), '[]'::jsonb)
+    FROM unnest(place.comments) WITH ORDINALITY AS u (c, n)
+);
+
+ALTER TABLE place DROP COLUMN comments;
+ALTER TABLE place RENAME COLUMN comments_jsonb TO comments;
+ALTER TABLE place ALTER COLUMN comments SET DEFAULT '[]'::jsonb;
+ALTER TABLE place ALTER COLUMN comments SET NOT NULL;
+
+-- jsonb_path_ops serves the @> containment filters.
+CREATE INDEX place_comments_idx ON place USING GIN (comments jsonb_path_ops);
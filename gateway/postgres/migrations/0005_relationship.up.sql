@@ -0,0 +1,16 @@
+-- People visiting or living in places. Relationships go with the person
+-- or place they refer to.
+CREATE TABLE relationship (
+    id text PRIMARY KEY,
+    person_id text NOT NULL REFERENCES person (id) ON DELETE CASCADE,
+    place_id text NOT NULL REFERENCES place (id) ON DELETE CASCADE,
+    role text NOT NULL CHECK (role IN ('visitor', 'resident')),
+    start_date date NOT NULL,
+    end_date date NULL,
+    timestamp bigint,
+    CHECK (end_date >= start_date)
+);
+
+-- Listings seek relationships by either side.
+CREATE INDEX relationship_person_idx ON relationship (person_id, place_id);
+CREATE INDEX relationship_place_idx ON relationship (place_id, person_id);
@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
+
+-- Soft deleted rows would reappear, remove them for good.
+DELETE FROM relationship WHERE deleted_at IS NOT NULL;
+DELETE FROM place WHERE deleted_at IS NOT NULL;
+DELETE FROM person WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE relationship
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at;
+ALTER TABLE place
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at;
+ALTER TABLE person
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at;
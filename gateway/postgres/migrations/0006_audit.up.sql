@@ -0,0 +1,47 @@
+-- Change times in unix seconds. Rows with deleted_at set are soft
+-- deleted and hidden from queries unless deleted rows are asked for.
+ALTER TABLE person
+    ADD COLUMN created_at bigint,
+    ADD COLUMN updated_at bigint,
+    ADD COLUMN deleted_at bigint NULL;
+ALTER TABLE place
+    ADD COLUMN created_at bigint,
+    ADD COLUMN updated_at bigint,
+    ADD COLUMN deleted_at bigint NULL;
+ALTER TABLE relationship
+    ADD COLUMN created_at bigint,
+    ADD COLUMN updated_at bigint,
+    ADD COLUMN deleted_at bigint NULL;
+
+UPDATE person SET created_at = timestamp, updated_at = timestamp;
+UPDATE place SET created_at = timestamp, updated_at = timestamp;
+UPDATE relationship SET created_at = timestamp, updated_at = timestamp;
+
+-- Every change to an entity, written in the transaction making it.
+-- before and after hold the changed columns, the whole row when the
+-- entity is created, deleted or imported.
+CREATE TABLE audit_log (
+    id bigserial PRIMARY KEY,
+    entity text NOT NULL,
+    entity_id text NOT NULL,
+    operation text NOT NULL CHECK (operation IN ('create', 'update', 'delete', 'import')),
+    actor text NOT NULL,
+    before jsonb NULL,
+    after jsonb NULL,
+    at bigint NOT NULL
+);
+
+CREATE INDEX audit_log_entity_idx ON audit_log (entity, entity_id, id);
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,3 @@
+ALTER TABLE relationship DROP COLUMN IF EXISTS version;
+ALTER TABLE place DROP COLUMN IF EXISTS version;
+ALTER TABLE person DROP COLUMN IF EXISTS version;
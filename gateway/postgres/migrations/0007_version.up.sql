@@ -0,0 +1,5 @@
+-- version counts the changes to a row. Updates can be made conditional
+-- on it so concurrent writers do not overwrite each other.
+ALTER TABLE person ADD COLUMN version bigint NOT NULL DEFAULT 1;
+ALTER TABLE place ADD COLUMN version bigint NOT NULL DEFAULT 1;
+ALTER TABLE relationship ADD COLUMN version bigint NOT NULL DEFAULT 1;
@@ -0,0 +1,25 @@
+-- Entity changes waiting to be published, written in the transaction
+-- making them and relayed by the app. Times are unix seconds.
+CREATE TABLE outbox (
+    id bigserial PRIMARY KEY,
+    topic text NOT NULL,
+    entity text NOT NULL,
+    entity_id text NOT NULL,
+    operation text NOT NULL,
+    actor text NOT NULL,
+    payload jsonb NULL,
+    created_at bigint NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at bigint NOT NULL,
+    delivered_at bigint NULL,
+    last_error text NULL
+);
+
+-- Relays claim due events, each entity's in order.
+CREATE INDEX outbox_pending_idx ON outbox (next_attempt_at, id)
+    WHERE delivered_at IS NULL;
+CREATE INDEX outbox_entity_pending_idx ON outbox (entity, entity_id, id)
+    WHERE delivered_at IS NULL;
+-- Delivered events are purged after a while.
+CREATE INDEX outbox_delivered_idx ON outbox (delivered_at)
+    WHERE delivered_at IS NOT NULL;
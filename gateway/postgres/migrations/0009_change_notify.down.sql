@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS place_notify_change ON place;
+DROP TRIGGER IF EXISTS person_notify_change ON person;
+DROP FUNCTION IF EXISTS notify_entity_change();
@@ -0,0 +1,37 @@
+-- Notifies entity_changes of each committed change to a person or
+-- place. Soft deletes are reported as deletes.
+CREATE FUNCTION notify_entity_change() RETURNS trigger AS $$
+DECLARE
+    changed record;
+    operation text;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+        operation := 'delete';
+    ELSIF TG_OP = 'INSERT' THEN
+        changed := NEW;
+        operation := 'create';
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        changed := NEW;
+        operation := 'delete';
+    ELSE
+        changed := NEW;
+        operation := 'update';
+    END IF;
+
+    PERFORM pg_notify('entity_changes', json_build_object(
+        'entity', TG_TABLE_NAME,
+        'id', changed.id,
+        'operation', operation,
+        'version', changed.version
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER person_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON person
+    FOR EACH ROW EXECUTE FUNCTION notify_entity_change();
+CREATE TRIGGER place_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON place
+    FOR EACH ROW EXECUTE FUNCTION notify_entity_change();
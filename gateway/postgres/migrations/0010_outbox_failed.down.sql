@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS outbox_failed_idx;
+DROP INDEX IF EXISTS outbox_pending_idx;
+DROP INDEX IF EXISTS outbox_entity_pending_idx;
+ALTER TABLE outbox DROP COLUMN IF EXISTS failed_at;
+
+CREATE INDEX outbox_pending_idx ON outbox (next_attempt_at, id)
+    WHERE delivered_at IS NULL;
+CREATE INDEX outbox_entity_pending_idx ON outbox (entity, entity_id, id)
+    WHERE delivered_at IS NULL;
@@ -0,0 +1,12 @@
+-- Events failing max_attempts times are set aside as failed, so they no
+-- longer hold back later events of their entity.
+ALTER TABLE outbox ADD COLUMN failed_at bigint NULL;
+
+DROP INDEX outbox_pending_idx;
+DROP INDEX outbox_entity_pending_idx;
+CREATE INDEX outbox_pending_idx ON outbox (next_attempt_at, id)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
+CREATE INDEX outbox_entity_pending_idx ON outbox (entity, entity_id, id)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
+CREATE INDEX outbox_failed_idx ON outbox (failed_at)
+    WHERE failed_at IS NOT NULL;
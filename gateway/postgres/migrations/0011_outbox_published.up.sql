@@ -0,0 +1,2 @@
+-- Publishers each event was delivered to, so retries skip them.
+ALTER TABLE outbox ADD COLUMN published text[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS place_timestamp_id_idx;
+DROP INDEX IF EXISTS place_telcode_id_idx;
+DROP INDEX IF EXISTS place_country_id_idx;
+
+DROP INDEX IF EXISTS person_timestamp_id_idx;
+DROP INDEX IF EXISTS person_email_id_idx;
+DROP INDEX IF EXISTS person_last_name_id_idx;
+DROP INDEX IF EXISTS person_first_name_id_idx;
+
+CREATE INDEX person_first_name_id_idx ON person (first_name, id);
+CREATE INDEX person_last_name_id_idx ON person (last_name, id);
+CREATE INDEX person_email_id_idx ON person (email, id);
+CREATE INDEX person_timestamp_id_idx ON person (timestamp, id);
+
+CREATE INDEX place_country_id_idx ON place (country, id);
+CREATE INDEX place_telcode_id_idx ON place (telcode, id);
+CREATE INDEX place_timestamp_id_idx ON place (timestamp, id);
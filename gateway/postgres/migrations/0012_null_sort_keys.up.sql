@@ -0,0 +1,18 @@
+-- Listings sort nulls as empty text or zero so keyset seeks do not skip
+-- them, index the same expressions they seek on.
+DROP INDEX IF EXISTS person_first_name_id_idx;
+DROP INDEX IF EXISTS person_last_name_id_idx;
+DROP INDEX IF EXISTS person_email_id_idx;
+DROP INDEX IF EXISTS person_timestamp_id_idx;
+DROP INDEX IF EXISTS place_country_id_idx;
+DROP INDEX IF EXISTS place_telcode_id_idx;
+DROP INDEX IF EXISTS place_timestamp_id_idx;
+
+CREATE INDEX person_first_name_id_idx ON person (coalesce(first_name, ''), id);
+CREATE INDEX person_last_name_id_idx ON person (coalesce(last_name, ''), id);
+CREATE INDEX person_email_id_idx ON person (coalesce(email, ''), id);
+CREATE INDEX person_timestamp_id_idx ON person (coalesce(timestamp, 0), id);
+
+CREATE INDEX place_country_id_idx ON place (coalesce(country, ''), id);
+CREATE INDEX place_telcode_id_idx ON place (coalesce(telcode, 0), id);
+CREATE INDEX place_timestamp_id_idx ON place (coalesce(timestamp, 0), id);
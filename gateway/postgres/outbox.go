@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// maxOutboxError bounds the delivery error kept on an outbox event.
+const maxOutboxError = 1000
+
+// OutboxEvent corresponds to the outbox table, a change to an entity
+// waiting to be published.
+type OutboxEvent struct {
+	ID int64 `json:"id" db:"id"`
+	// Topic is the entity and operation, such as person.update.
+	Topic     string    `json:"topic"     db:"topic"`
+	Entity    string    `json:"entity"    db:"entity"`
+	EntityID  string    `json:"entity_id" db:"entity_id"`
+	Operation Operation `json:"operation" db:"operation"`
+	Actor     string    `json:"actor"     db:"actor"`
+	// Payload is the row after the change, before it when deleted.
+	Payload RawJSON `json:"payload" db:"payload"`
+	// CreatedAt, NextAttemptAt, DeliveredAt and FailedAt are in unix seconds.
+	CreatedAt     int64         `json:"created_at"      db:"created_at"`
+	Attempts      int           `json:"attempts"        db:"attempts"`
+	NextAttemptAt int64         `json:"next_attempt_at" db:"next_attempt_at"`
+	DeliveredAt   sql.NullInt64 `json:"delivered_at"    db:"delivered_at"`
+	// FailedAt is set once delivery is given up on.
+	FailedAt  sql.NullInt64  `json:"failed_at"  db:"failed_at"`
+	LastError sql.NullString `json:"last_error" db:"last_error"`
+	// Published names the publishers the event was delivered to.
+	Published pq.StringArray `json:"published" db:"published"`
+}
+
+// outboxColumns are the columns RelayOutbox reads.
+const outboxColumns = `"id", "topic", "entity", "entity_id", "operation", "actor", "payload",
+	"created_at", "attempts", "next_attempt_at", "delivered_at", "failed_at", "last_error",
+	"published"`
+
+// writeOutbox adds an event for op on the row of table with id in tx.
+func writeOutbox(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	table, id string,
+	op Operation,
+	row RawJSON,
+	now int64,
+) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO "outbox" ("topic", "entity", "entity_id", "operation", "actor", "payload",
+			"created_at", "next_attempt_at")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)`,
+		table+"."+string(op),
+		table,
+		id,
+		string(op),
+		actorOf(ctx),
+		row,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("outbox %w", err)
+	}
+	return nil
+}
+
+// OutboxRelay configures RelayOutbox.
+type OutboxRelay struct {
+	// Limit is the most events claimed at once.
+	Limit int
+	// Lease is how long claimed events are held before they are due
+	// again, should the relay stop before marking them.
+	Lease time.Duration
+	// MaxAttempts marks events failed after as many failed attempts.
+	MaxAttempts int
+	// Publish delivers an event to the publishers not in its Published,
+	// returning those it delivered to.
+	Publish func(OutboxEvent) ([]string, error)
+	// RetryAfter is the wait before retrying an event after attempts.
+	RetryAfter func(attempts int) time.Duration
+}
+
+// RelayOutbox claims up to r.Limit due events and publishes each in
+// id order. Events claimed by another relay are skipped, as are events
+// of an entity with an earlier pending event, so each entity's events
+// are published in order. Claims are committed before publishing, and
+// each event is marked as soon as it is published: delivered, retried
+// after r.RetryAfter its attempts, or failed after r.MaxAttempts and no
+// longer retried. It returns how many events were claimed.
+func (g *gateway) RelayOutbox(ctx context.Context, r OutboxRelay) (int, error) {
+	var events []OutboxEvent
+	// Read committed lets relays skip each other's rows without failing.
+	err := g.runTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted}, func(tx *sqlx.Tx) error {
+		events = nil
+		now := time.Now().UTC()
+		err := tx.SelectContext(
+			ctx,
+			&events,
+			`SELECT `+outboxColumns+` FROM "outbox" AS o
+			WHERE "delivered_at" IS NULL AND "failed_at" IS NULL AND "next_attempt_at" <= $1
+			AND NOT EXISTS (
+				SELECT 1 FROM "outbox" AS p
+				WHERE p."entity" = o."entity" AND p."entity_id" = o."entity_id"
+				AND p."delivered_at" IS NULL AND p."failed_at" IS NULL AND p."id" < o."id"
+			)
+			ORDER BY "id" LIMIT $2
+			FOR UPDATE SKIP LOCKED`,
+			now.Unix(),
+			r.Limit,
+		)
+		if err != nil {
+			return fmt.Errorf("SelectContext %w", err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE "outbox" SET "next_attempt_at" = $1 WHERE "id" = ANY($2)`,
+			now.Add(r.Lease).Unix(),
+			pq.Int64Array(ids),
+		)
+		if err != nil {
+			return fmt.Errorf("claim %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range events {
+		// Unpublished events stay claimed until the lease ends.
+		if ctx.Err() != nil {
+			break
+		}
+		published, pubErr := r.Publish(e)
+		err := g.markOutbox(ctx, r, e, published, pubErr)
+		if err != nil {
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
+
+// markOutbox records an attempt to publish e. It runs even once ctx is
+// done, so a publish is not repeated for want of its mark.
+func (g *gateway) markOutbox(
+	ctx context.Context,
+	r OutboxRelay,
+	e OutboxEvent,
+	published []string,
+	pubErr error,
+) error {
+	ctx, cancel := g.timeout(context.WithoutCancel(ctx))
+	defer cancel()
+
+	now := time.Now().UTC()
+	// published adds the publishers delivered to, once each.
+	const publishedSet = `"published" = ARRAY(SELECT DISTINCT unnest("published" || $1::text[]))`
+	var err error
+	switch {
+	case pubErr == nil:
+		_, err = g.db.ExecContext(
+			ctx,
+			`UPDATE "outbox" SET `+publishedSet+`, "delivered_at" = $2,
+				"attempts" = "attempts" + 1, "last_error" = NULL
+			WHERE "id" = $3`,
+			pq.StringArray(published),
+			now.Unix(),
+			e.ID,
+		)
+	case e.Attempts+1 >= r.MaxAttempts:
+		_, err = g.db.ExecContext(
+			ctx,
+			`UPDATE "outbox" SET `+publishedSet+`, "failed_at" = $2,
+				"attempts" = "attempts" + 1, "last_error" = $3
+			WHERE "id" = $4`,
+			pq.StringArray(published),
+			now.Unix(),
+			outboxError(pubErr),
+			e.ID,
+		)
+	default:
+		_, err = g.db.ExecContext(
+			ctx,
+			`UPDATE "outbox" SET `+publishedSet+`, "next_attempt_at" = $2,
+				"attempts" = "attempts" + 1, "last_error" = $3
+			WHERE "id" = $4`,
+			pq.StringArray(published),
+			now.Add(r.RetryAfter(e.Attempts+1)).Unix(),
+			outboxError(pubErr),
+			e.ID,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("mark outbox event %d %w", e.ID, err)
+	}
+	return nil
+}
+
+// outboxError is err's message bounded to maxOutboxError bytes.
+func outboxError(err error) string {
+	msg := err.Error()
+	if len(msg) > maxOutboxError {
+		msg = strings.ToValidUTF8(msg[:maxOutboxError], "")
+	}
+	return msg
+}
+
+// PurgeOutbox removes events delivered before the unix time before.
+// Failed events are kept for inspection.
+func (g *gateway) PurgeOutbox(ctx context.Context, before int64) (int64, error) {
+	ctx, cancel := g.timeout(ctx)
+	defer cancel()
+
+	res, err := g.db.ExecContext(
+		ctx,
+		`DELETE FROM "outbox" WHERE "delivered_at" < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("ExecContext %w", err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("RowsAffected %w", err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,236 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Page selects one page of an ordered listing. Rows are ordered by
+// OrderBy then id, so pages stay stable while rows are written.
+type Page struct {
+	// OrderBy is the sort column, id when empty.
+	OrderBy string
+	Desc    bool
+	// Size is the maximum rows returned.
+	Size int
+	// After resumes after the row the cursor was taken from.
+	After *Cursor
+}
+
+// Cursor is the sort key of the last row of a page.
+type Cursor struct {
+	Key interface{} `json:"k"`
+	ID  string      `json:"id"`
+}
+
+// CursorFor returns the cursor after row when ordered by orderBy.
+func CursorFor(row interface{}, orderBy string) (Cursor, error) {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	t, err := tableOf(v.Type())
+	if err != nil {
+		return Cursor{}, err
+	}
+
+	col, err := t.sortColumn(orderBy)
+	if err != nil {
+		return Cursor{}, err
+	}
+	var id string
+	switch key := v.Field(t.byName["id"].index).Interface().(type) {
+	case string:
+		id = key
+	case int64:
+		id = strconv.FormatInt(key, 10)
+	default:
+		return Cursor{}, fmt.Errorf("%w: %s id is not a string or int64", ErrInvalidFilter, v.Type())
+	}
+
+	return Cursor{
+		Key: v.Field(col.index).Interface(),
+		ID:  id,
+	}, nil
+}
+
+// sortColumn resolves a sort column, id when empty.
+func (t *table) sortColumn(orderBy string) (column, error) {
+	if orderBy == "" {
+		orderBy = "id"
+	}
+	col, ok := t.byName[orderBy]
+	if !ok || col.kind == kindArray || col.kind == kindJSON {
+		return column{}, fmt.Errorf("%w: cannot order by %q", ErrInvalidFilter, orderBy)
+	}
+	return col, nil
+}
+
+// sortKey returns the expression rows are ordered by for col of rt.
+// Nullable columns scanned into Go strings and numbers sort nulls as
+// the zero value their cursor holds, since a null key would compare
+// as unknown and drop its rows from every later page.
+func sortKey(rt reflect.Type, col column) string {
+	quoted := pq.QuoteIdentifier(col.name)
+	if col.name == "id" {
+		return quoted
+	}
+	switch rt.Field(col.index).Type.Kind() {
+	case reflect.String:
+		return "coalesce(" + quoted + ", '')"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "coalesce(" + quoted + ", 0)"
+	}
+	return quoted
+}
+
+// pageQuery builds a SELECT of one page of rows matching f, fetching
+// one extra row to tell whether more follow.
+func pageQuery(name string, row interface{}, f Filter, page Page) (string, []interface{}, error) {
+	if page.Size < 1 {
+		return "", nil, fmt.Errorf("%w: page size must be positive", ErrInvalidFilter)
+	}
+
+	rt := reflect.Indirect(reflect.ValueOf(row)).Type()
+	t, err := tableOf(rt)
+	if err != nil {
+		return "", nil, err
+	}
+	col, err := t.sortColumn(page.OrderBy)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", t.names(), pq.QuoteIdentifier(name))
+	where, args, err := t.where(f)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cmp, dir := ">", "ASC"
+	if page.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	sortCol := sortKey(rt, col)
+
+	var conds []string
+	if where != "" {
+		conds = append(conds, "("+where+")")
+	}
+	if page.After != nil {
+		if col.name == "id" {
+			args = append(args, page.After.ID)
+			conds = append(conds, fmt.Sprintf(`"id" %s $%d`, cmp, len(args)))
+		} else {
+			args = append(args, page.After.Key, page.After.ID)
+			conds = append(conds, fmt.Sprintf(
+				`(%s, "id") %s ($%d, $%d)`,
+				sortCol,
+				cmp,
+				len(args)-1,
+				len(args),
+			))
+		}
+	}
+	for i, cond := range conds {
+		if i == 0 {
+			query += " WHERE " + cond
+		} else {
+			query += " AND " + cond
+		}
+	}
+
+	if col.name == "id" {
+		query += fmt.Sprintf(` ORDER BY "id" %s`, dir)
+	} else {
+		query += fmt.Sprintf(` ORDER BY %s %s, "id" %s`, sortCol, dir, dir)
+	}
+	args = append(args, page.Size+1)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+	return query, args, nil
+}
+
+// estimate returns the planner's row estimate for visible rows of name
+// matching f. It is cheap but only as fresh as the table statistics.
+func (g *gateway) estimate(ctx context.Context, name string, row interface{}, f Filter) (int64, error) {
+	ctx, cancel := g.timeout(ctx)
+	defer cancel()
+
+	t, err := tableOf(reflect.Indirect(reflect.ValueOf(row)).Type())
+	if err != nil {
+		return 0, err
+	}
+	f, err = visible(ctx, row, f)
+	if err != nil {
+		return 0, err
+	}
+	where, args, err := t.where(f)
+	if err != nil {
+		return 0, fmt.Errorf("where %w", err)
+	}
+
+	query := "EXPLAIN (FORMAT JSON) SELECT 1 FROM " + pq.QuoteIdentifier(name)
+	if where != "" {
+		query += " WHERE " + where
+	}
+
+	var plan string
+	err = g.read(ctx, func(db *sqlx.DB) error {
+		return db.GetContext(ctx, &plan, query, args...)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("GetContext %w", err)
+	}
+
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	err = json.Unmarshal([]byte(plan), &plans)
+	if err != nil || len(plans) == 0 {
+		return 0, fmt.Errorf("parse plan %q", plan)
+	}
+
+	return int64(plans[0].Plan.Rows), nil
+}
+
+// list selects one page of rows of name into dest, a pointer to a
+// slice, and reports whether more rows follow. Soft deleted rows are
+// left out unless ctx includes them.
+func list(
+	ctx context.Context,
+	q sqlx.QueryerContext,
+	name string,
+	dest interface{},
+	f Filter,
+	page Page,
+) (bool, error) {
+	rows := reflect.ValueOf(dest).Elem()
+	row := reflect.New(rows.Type().Elem()).Interface()
+	f, err := visible(ctx, row, f)
+	if err != nil {
+		return false, err
+	}
+	query, args, err := pageQuery(name, row, f, page)
+	if err != nil {
+		return false, fmt.Errorf("pageQuery %w", err)
+	}
+
+	err = sqlx.SelectContext(ctx, q, dest, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("SelectContext %w", err)
+	}
+
+	if rows.Len() > page.Size {
+		rows.Set(rows.Slice(0, page.Size))
+		return true, nil
+	}
+	return false, nil
+}
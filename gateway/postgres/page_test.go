@@ -0,0 +1,28 @@
+package postgres
+
+import "testing"
+
+func TestPageQueryGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		row   interface{}
+		page  Page
+	}{
+		{"first", "person", Person{}, Page{Size: 10}},
+		{"after_id", "person", Person{}, Page{Size: 10, After: &Cursor{ID: "b"}}},
+		{"after_text", "person", Person{}, Page{OrderBy: "first_name", Size: 10, After: &Cursor{Key: "", ID: "b"}}},
+		{"after_number_desc", "place", Place{}, Page{OrderBy: "telcode", Desc: true, Size: 10, After: &Cursor{Key: 64, ID: "b"}}},
+		{"date", "relationship", Relationship{}, Page{OrderBy: "start_date", Size: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := pageQuery(tt.table, tt.row, Where("id", OpNe, "x"), tt.page)
+			if err != nil {
+				t.Fatalf("pageQuery: %v", err)
+			}
+			golden(t, "page_"+tt.name, render(query, args))
+		})
+	}
+}
@@ -3,276 +3,173 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
 	"go.uber.org/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
 )
 
 // Gateway defines methods for interacting with postgres.
+// Each call is bounded by postgres.query_timeout and canceled with ctx.
+// Deletes are soft, deleted rows are only read with WithDeleted, and
+// changes are audited as made by the WithActor actor. Changes to a row
+// count up its version, and are conditional on it with IfVersion.
+// Each change also adds an outbox event, published with RelayOutbox.
+// Reads outside transactions go to a healthy replica when there is
+// one, unless ctx comes from WithPrimary.
 type Gateway interface {
-	SelectPlaceByFilter(f Place) ([]Place, error)
-	InitializeDB() error
-	PopulateDB() error
+	CreatePerson(ctx context.Context, p Person) (Person, error)
+	GetPerson(ctx context.Context, id string) (Person, error)
+	UpdatePerson(ctx context.Context, p Person, columns []string) (Person, error)
+	DeletePerson(ctx context.Context, id string) error
+	ListPeople(ctx context.Context, f Filter, page Page) ([]Person, bool, error)
+	EstimatePeople(ctx context.Context, f Filter) (int64, error)
+	ImportPeople(ctx context.Context, next func() (Person, error), upsert bool) (int64, error)
+	ExportPeople(ctx context.Context, fn func(Person) error) error
+	CreatePlace(ctx context.Context, p Place) (Place, error)
+	GetPlace(ctx context.Context, id string) (Place, error)
+	UpdatePlace(ctx context.Context, p Place, columns []string) (Place, error)
+	DeletePlace(ctx context.Context, id string) error
+	SelectPlaceByFilter(ctx context.Context, f Filter, page Page) ([]Place, bool, error)
+	EstimatePlaces(ctx context.Context, f Filter) (int64, error)
+	AddPlaceComment(ctx context.Context, id string, c Comment, max int) error
+	RemovePlaceComment(ctx context.Context, id, commentID string) error
+	ImportPlaces(ctx context.Context, next func() (Place, error), upsert bool) (int64, error)
+	ExportPlaces(ctx context.Context, fn func(Place) error) error
+	CreateRelationship(ctx context.Context, r Relationship) (Relationship, error)
+	DeleteRelationship(ctx context.Context, id string) error
+	ListRelationships(ctx context.Context, f Filter, page Page) ([]Relationship, bool, error)
+	ListHistory(ctx context.Context, entity, id string, page Page) ([]AuditEntry, bool, error)
+	RelayOutbox(ctx context.Context, r OutboxRelay) (int, error)
+	PurgeOutbox(ctx context.Context, before int64) (int64, error)
 }
 
 // gateway defines implementation of Gateway interface.
 type gateway struct {
-	db     *sqlx.DB
-	txOpts *sql.TxOptions
+	db *sqlx.DB
+	// replicas serve reads, nil without any.
+	replicas *Replicas
+	txOpts   *sql.TxOptions
+	txCfg    TxConfig
+	// queryTimeout bounds each Gateway call but bulk imports and
+	// exports, zero for none.
+	queryTimeout time.Duration
+	log          *zap.Logger
 }
 
-// New is the Gateway interface constructor.
-func New(cfg config.Provider) (Gateway, error) {
-	connStr := fmt.Sprintf(
-		"user=%s dbname=%s password=%s sslmode=%s",
-		cfg.Get("postgres.user").String(),
-		cfg.Get("postgres.db_name").String(),
-		cfg.Get("postgres.password").String(),
-		cfg.Get("postgres.ssl_mode").String(),
-	)
-
-	db, err := sqlx.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("sql Open %w", err)
-	}
+// Params defines Open requirements.
+type Params struct {
+	fx.In
 
-	return &gateway{
-		db:     db,
-		txOpts: &sql.TxOptions{Isolation: sql.LevelSerializable},
-	}, nil
+	Cfg   config.Provider
+	Lc    fx.Lifecycle
+	Log   *zap.Logger
+	Stats *QueryStats
 }
 
-// selectByFilter is a sample method for variable filter queries.
-func (g *gateway) SelectPlaceByFilter(f Place) ([]Place, error) {
-	// Convert struct to map string interface.
-	queryMap, err := toMap(f)
-	if err != nil {
-		return nil, fmt.Errorf("toMap %w", err)
-	}
-
-	// Build the query.
-	query := "SELECT * FROM place"
-	var index int
-	var prefix string
-	for key := range queryMap {
-		if index == 0 {
-			prefix = "WHERE"
-		}
-		query += fmt.Sprintf(" %s %s=:%s", prefix, key, key)
-		index += 1
+// Open configures the database pool, whose queries are counted in
+// Stats. The connection is checked as the app starts and closed as it
+// stops.
+func Open(p Params) (*sqlx.DB, error) {
+	pcfg := Config{
+		Instrument: defaultInstrument,
 	}
-
-	// Begin transaction.
-	ctx := context.Background()
-	tx, err := g.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	err := p.Cfg.Get("postgres").Populate(&pcfg)
 	if err != nil {
-		return nil, fmt.Errorf("BeginTxx %w", err)
+		return nil, fmt.Errorf("postgres config %w", err)
 	}
-	defer tx.Rollback()
-
-	// Prepare query as built statement.
-	var places []Place
-	preparedStmnt, err := tx.PrepareNamedContext(ctx, query)
+	err = pcfg.validate()
 	if err != nil {
-		return nil, fmt.Errorf("PrepareNamedContext %w", err)
+		return nil, err
 	}
 
-	// Execute query with params.
-	err = preparedStmnt.SelectContext(ctx, &places, queryMap)
+	db, err := openDB(pcfg, "primary", p.Stats, p.Log)
 	if err != nil {
-		return nil, fmt.Errorf("SelectContext %w", err)
+		return nil, err
 	}
 
-	// End transaction.
-	err = tx.Commit()
-	if err != nil {
-		return nil, fmt.Errorf("Commit %w", err)
-	}
+	p.Lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			err := db.PingContext(ctx)
+			if err != nil {
+				return fmt.Errorf("postgres ping %s %w", pcfg.sanitizedDSN(), err)
+			}
+			p.Log.Info("postgres connected", zap.String("dsn", pcfg.sanitizedDSN()))
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			return db.Close()
+		},
+	})
 
-	return places, nil
+	return db, nil
 }
 
-// InitializeDB sets up DB tables.
-func (g *gateway) InitializeDB() error {
-	// Setup the table using the defined schema.
-	_, err := g.db.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("Exec %w", err)
-	}
+// GatewayParams defines New requirements.
+type GatewayParams struct {
+	fx.In
 
-	return nil
+	DB       *sqlx.DB
+	Replicas *Replicas `optional:"true"`
+	Cfg      config.Provider
+	Log      *zap.Logger
 }
 
-// PopulateDB sets up test data in tables.
-func (g *gateway) PopulateDB() error {
-	// Open a new transaction to update table with data.
-	ctx := context.Background()
-	tx, err := g.db.BeginTxx(ctx, g.txOpts)
-	if err != nil {
-		return fmt.Errorf("schema transaction begin %w", err)
-	}
-	defer tx.Rollback()
-
-	// Begin insertions to populate table with rows.
-	// PrepareNamedContext(ctx context.Context, query string) (*NamedStmt, error)
-	// ExecContext(ctx context.Context, arg interface{}) (sql.Result, error)
-	// https://github.com/jmoiron/sqlx/blob/v1.3.5/sqlx_context.go#L315
-	personQuery, err := tx.PrepareNamedContext(
-		ctx,
-		"INSERT INTO person (id, first_name, last_name, email, timestamp) VALUES (:id, :first_name, :last_name, :email, :timestamp)",
-	)
-	if err != nil {
-		return fmt.Errorf("PrepareNamedContext %w", err)
-	}
-
-	_, err = personQuery.ExecContext(
-		ctx,
-		&Person{
-			ID:        uuid.New().String(),
-			FirstName: "Jason",
-			LastName:  "Moiron",
-			Email:     "jmoiron@jmoiron.net",
-			Timestamp: time.Now().UTC().Unix(),
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("first insert exec %w", err)
-	}
-
-	_, err = personQuery.ExecContext(
-		ctx,
-		&Person{
-			ID:        uuid.New().String(),
-			FirstName: "John",
-			LastName:  "Doe",
-			Email:     "johndoeDNE@gmail.net",
-			Timestamp: time.Now().UTC().Unix(),
+// New is the Gateway interface constructor.
+func New(p GatewayParams) (Gateway, error) {
+	pcfg := Config{
+		QueryTimeout: 5 * time.Second,
+		Tx: TxConfig{
+			MaxAttempts: 5,
+			Backoff:     20 * time.Millisecond,
+			MaxBackoff:  time.Second,
 		},
-	)
-	if err != nil {
-		return fmt.Errorf("second insert exec %w", err)
 	}
-
-	placeQuery, err := tx.PrepareNamedContext(
-		ctx,
-		"INSERT INTO place (id, country, city, telcode, comments, timestamp) VALUES (:id, :country, :city, :telcode, :comments, :timestamp)",
-	)
-	_, err = placeQuery.ExecContext(
-		ctx,
-		&Place{
-			ID:      uuid.New().String(),
-			Country: "United States",
-			City: sql.NullString{
-				String: "New York",
-				Valid:  true,
-			},
-			TelCode:   1,
-			Comments:  []string{},
-			Timestamp: time.Now().UTC().Unix(),
-		},
-	)
+	err := p.Cfg.Get("postgres").Populate(&pcfg)
 	if err != nil {
-		return fmt.Errorf("third insert exec %w", err)
+		return nil, fmt.Errorf("postgres config %w", err)
 	}
-
-	_, err = placeQuery.ExecContext(
-		ctx,
-		&Place{
-			ID:        uuid.New().String(),
-			Country:   "Hong Kong",
-			TelCode:   852,
-			Comments:  []string{`{"key_one": "value_one"}`},
-			Timestamp: time.Now().UTC().Unix(),
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("fourth insert exec %w", err)
+	if pcfg.Tx.MaxAttempts < 1 {
+		return nil, fmt.Errorf("postgres tx max_attempts must be at least 1")
 	}
 
-	_, err = placeQuery.ExecContext(
-		ctx,
-		&Place{
-			ID:        uuid.New().String(),
-			Country:   "Singapore",
-			TelCode:   65,
-			Comments:  []string{`{"key1": "value1"}`},
-			Timestamp: time.Now().UTC().Unix(),
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("fifth insert exec %w", err)
-	}
-
-	// Example of inserting a row using a populated struct.
-	_, err = personQuery.ExecContext(
-		ctx,
-		&Person{
-			ID:        uuid.New().String(),
-			FirstName: "Jane",
-			LastName:  "Citizen",
-			Email:     "jane.citzen@example.com",
-			Timestamp: time.Now().UTC().Unix(),
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("sixth insert exec %w", err)
-	}
+	return &gateway{
+		db:           p.DB,
+		replicas:     p.Replicas,
+		txOpts:       &sql.TxOptions{Isolation: sql.LevelSerializable},
+		txCfg:        pcfg.Tx,
+		queryTimeout: pcfg.QueryTimeout,
+		log:          p.Log,
+	}, nil
+}
 
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("commit transaction %w", err)
+// timeout bounds ctx by the query timeout.
+func (g *gateway) timeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
-
-	return nil
+	return context.WithTimeout(ctx, g.queryTimeout)
 }
 
-// toMap parses a struct to a map accounting for sql.Nullx types.
-// Supports using a single struct for reading and writing rows.
-func toMap(p interface{}) (map[string]interface{}, error) {
-	queryBytes, err := json.Marshal(p)
-	if err != nil {
-		return nil, err
-	}
+// SelectPlaceByFilter returns a page of places matching f and whether more follow.
+func (g *gateway) SelectPlaceByFilter(ctx context.Context, f Filter, page Page) ([]Place, bool, error) {
+	ctx, cancel := g.timeout(ctx)
+	defer cancel()
 
-	var queryMap map[string]interface{}
-	err = json.Unmarshal(queryBytes, &queryMap)
+	var places []Place
+	var more bool
+	err := g.read(ctx, func(db *sqlx.DB) error {
+		places = nil
+		var err error
+		more, err = list(ctx, db, "place", &places, f, page)
+		return err
+	})
 	if err != nil {
-		return nil, err
-	}
-
-	// Resolve map values to underlying values.
-	// ONLY handles sql.Nullx type values.
-	nullables := []string{
-		"String",
-		"Bool",
-		"Int64",
-		"Byte",
-		"Float64",
-		"Int16",
-		"Int32",
-		"Time",
-	}
-	// Parse resulting maps of sql.Nullx types. Remove invalids.
-	for key, value := range queryMap {
-		if asMap, ok := value.(map[string]interface{}); ok {
-			fmt.Printf("key: %s - as map: %v\n", key, asMap)
-			if !asMap["Valid"].(bool) {
-				delete(queryMap, key)
-				continue
-			}
-
-			for _, nullable := range nullables {
-				if subVal, ok := asMap[nullable]; ok {
-					queryMap[key] = subVal
-				}
-			}
-		}
+		return nil, false, err
 	}
 
-	return queryMap, nil
+	return places, more, nil
 }
@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// uniqueViolation is the SQLSTATE for unique constraint failures.
+	uniqueViolation = "23505"
+	// foreignKeyViolation is the SQLSTATE for rows referencing missing rows.
+	foreignKeyViolation = "23503"
+	// queryCanceled is the SQLSTATE for statements canceled by the client.
+	queryCanceled = "57014"
+)
+
+var (
+	// ErrNotFound is returned when a row does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when a row's key is taken.
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+// insertQuery builds an INSERT of every column of row into name.
+func insertQuery(name string, row interface{}) (string, []interface{}, error) {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	t, err := tableOf(v.Type())
+	if err != nil {
+		return "", nil, err
+	}
+
+	placeholders := make([]string, len(t.columns))
+	args := make([]interface{}, len(t.columns))
+	for i, col := range t.columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = v.Field(col.index).Interface()
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		pq.QuoteIdentifier(name),
+		t.names(),
+		strings.Join(placeholders, ", "),
+	)
+	return query, args, nil
+}
+
+// upsertQuery builds an insertQuery replacing the row with the same id.
+func upsertQuery(name string, row interface{}) (string, []interface{}, error) {
+	query, args, err := insertQuery(name, row)
+	if err != nil {
+		return "", nil, err
+	}
+	t, err := tableOf(reflect.Indirect(reflect.ValueOf(row)).Type())
+	if err != nil {
+		return "", nil, err
+	}
+	return query + t.onConflictUpdate(name, false), args, nil
+}
+
+// onConflictUpdate returns a clause replacing every column but id of
+// the row of name an insert conflicts with, counting a version. With
+// keepUnset, a zero created_at or null deleted_at keeps the stored one.
+func (t *table) onConflictUpdate(name string, keepUnset bool) string {
+	stored := pq.QuoteIdentifier(name) + "."
+	var assignments []string
+	for _, col := range t.columns {
+		if col.name == "id" {
+			continue
+		}
+		quoted := pq.QuoteIdentifier(col.name)
+		switch {
+		case col.name == versionColumn:
+			assignments = append(assignments, quoted+" = "+stored+quoted+" + 1")
+		case keepUnset && col.name == "created_at":
+			assignments = append(assignments, fmt.Sprintf(
+				"%s = coalesce(nullif(EXCLUDED.%s, 0), %s%s)", quoted, quoted, stored, quoted,
+			))
+		case keepUnset && col.name == deletedColumn:
+			assignments = append(assignments, fmt.Sprintf(
+				"%s = coalesce(EXCLUDED.%s, %s%s)", quoted, quoted, stored, quoted,
+			))
+		default:
+			assignments = append(assignments, quoted+" = EXCLUDED."+quoted)
+		}
+	}
+	return ` ON CONFLICT ("id") DO UPDATE SET ` + strings.Join(assignments, ", ")
+}
+
+// updateQuery builds an UPDATE of columns of row in name by id,
+// returning the updated row. Columns are set in struct order, the
+// version counts up and soft deleted rows are left alone.
+func updateQuery(name string, row interface{}, columns []string) (string, []interface{}, error) {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	t, err := tableOf(v.Type())
+	if err != nil {
+		return "", nil, err
+	}
+
+	set := make(map[string]bool, len(columns))
+	for _, name := range columns {
+		if _, ok := t.byName[name]; !ok || name == "id" || name == versionColumn {
+			return "", nil, fmt.Errorf("%w: cannot update column %q", ErrInvalidFilter, name)
+		}
+		set[name] = true
+	}
+
+	var assignments []string
+	var args []interface{}
+	for _, col := range t.columns {
+		if !set[col.name] {
+			continue
+		}
+		args = append(args, v.Field(col.index).Interface())
+		assignments = append(assignments, fmt.Sprintf(
+			"%s = $%d",
+			pq.QuoteIdentifier(col.name),
+			len(args),
+		))
+	}
+	if len(assignments) == 0 {
+		return "", nil, fmt.Errorf("%w: no columns to update", ErrInvalidFilter)
+	}
+	if _, ok := t.byName[versionColumn]; ok {
+		quoted := pq.QuoteIdentifier(versionColumn)
+		assignments = append(assignments, quoted+" = "+quoted+" + 1")
+	}
+
+	idCol, ok := t.byName["id"]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s has no id column", ErrInvalidFilter, v.Type())
+	}
+	args = append(args, v.Field(idCol.index).Interface())
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE \"id\" = $%d",
+		pq.QuoteIdentifier(name),
+		strings.Join(assignments, ", "),
+		len(args),
+	)
+	if t.softDeletes() {
+		query += fmt.Sprintf(" AND %s IS NULL", pq.QuoteIdentifier(deletedColumn))
+	}
+	query += " RETURNING " + t.names()
+	return query, args, nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint failure.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+// isForeignKeyViolation reports whether err is a foreign key failure.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation
+}
+
+// IsCanceled reports whether err came from a statement stopped by a
+// canceled or expired context, including the query timeout.
+func IsCanceled(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == queryCanceled
+}
@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Role is how a person relates to a place.
+type Role string
+
+// Supported roles.
+const (
+	RoleVisitor  Role = "visitor"
+	RoleResident Role = "resident"
+)
+
+// RelationshipQuery selects relationships, zero fields match any.
+type RelationshipQuery struct {
+	PersonID string
+	PlaceID  string
+	Role     Role
+	// ActiveOn matches relationships spanning the date.
+	ActiveOn time.Time
+}
+
+// Filter returns the relationship filter of q.
+func (q RelationshipQuery) Filter() Filter {
+	var filters []Filter
+	if q.PersonID != "" {
+		filters = append(filters, Where("person_id", OpEq, q.PersonID))
+	}
+	if q.PlaceID != "" {
+		filters = append(filters, Where("place_id", OpEq, q.PlaceID))
+	}
+	if q.Role != "" {
+		filters = append(filters, Where("role", OpEq, string(q.Role)))
+	}
+	if !q.ActiveOn.IsZero() {
+		filters = append(filters, ActiveOn(q.ActiveOn))
+	}
+	// Relationships of deleted people and places are gone with them.
+	filters = append(filters, OfPeople(Filter{}), AtPlaces(Filter{}))
+	return And(filters...)
+}
+
+// ActiveOn matches relationships spanning day.
+func ActiveOn(day time.Time) Filter {
+	return And(
+		Where("start_date", OpLte, day),
+		Or(
+			Where("end_date", OpIsNull, nil),
+			Where("end_date", OpGte, day),
+		),
+	)
+}
+
+// PeopleRelated matches people with a relationship matching f. People
+// who visited Singapore are
+//
+//	PeopleRelated(And(
+//		Where("role", OpEq, "visitor"),
+//		AtPlaces(Where("country", OpEq, "Singapore")),
+//	))
+func PeopleRelated(f Filter) Filter {
+	return Where("id", OpIn, Subquery{
+		Table:  "relationship",
+		Row:    Relationship{},
+		Column: "person_id",
+		Filter: f,
+	})
+}
+
+// PlacesRelated matches places with a relationship matching f.
+func PlacesRelated(f Filter) Filter {
+	return Where("id", OpIn, Subquery{
+		Table:  "relationship",
+		Row:    Relationship{},
+		Column: "place_id",
+		Filter: f,
+	})
+}
+
+// OfPeople matches relationships of people matching f.
+func OfPeople(f Filter) Filter {
+	return Where("person_id", OpIn, Subquery{
+		Table:  "person",
+		Row:    Person{},
+		Column: "id",
+		Filter: f,
+	})
+}
+
+// AtPlaces matches relationships with places matching f.
+func AtPlaces(f Filter) Filter {
+	return Where("place_id", OpIn, Subquery{
+		Table:  "place",
+		Row:    Place{},
+		Column: "id",
+		Filter: f,
+	})
+}
+
+// CreateRelationship inserts a relationship and returns it as stored,
+// ErrNotFound when its person or place does not exist or is deleted.
+func (g *gateway) CreateRelationship(ctx context.Context, r Relationship) (Relationship, error) {
+	var created Relationship
+	err := g.audited(ctx, "relationship", r.ID, AuditCreate, func(tx *sqlx.Tx) error {
+		var ok bool
+		err := tx.GetContext(
+			ctx,
+			&ok,
+			`SELECT EXISTS (SELECT 1 FROM "person" WHERE "id" = $1 AND "deleted_at" IS NULL)
+			AND EXISTS (SELECT 1 FROM "place" WHERE "id" = $2 AND "deleted_at" IS NULL)`,
+			r.PersonID,
+			r.PlaceID,
+		)
+		if err != nil {
+			return fmt.Errorf("GetContext %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("person or place %w", ErrNotFound)
+		}
+
+		err = insertRow(ctx, tx, "relationship", &created, r)
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("person or place %w", ErrNotFound)
+		}
+		return err
+	})
+	return created, err
+}
+
+// DeleteRelationship removes the relationship with id.
+func (g *gateway) DeleteRelationship(ctx context.Context, id string) error {
+	return g.delete(ctx, "relationship", id)
+}
+
+// ListRelationships returns a page of relationships matching f and
+// whether more follow.
+func (g *gateway) ListRelationships(
+	ctx context.Context,
+	f Filter,
+	page Page,
+) ([]Relationship, bool, error) {
+	ctx, cancel := g.timeout(ctx)
+	defer cancel()
+
+	var relationships []Relationship
+	var more bool
+	err := g.read(ctx, func(db *sqlx.DB) error {
+		relationships = nil
+		var err error
+		more, err = list(ctx, db, "relationship", &relationships, f, page)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return relationships, more, nil
+}
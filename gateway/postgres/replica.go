@@ -0,0 +1,293 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// ReplicaConfig defines read replicas under postgres.replicas.
+type ReplicaConfig struct {
+	// Hosts are host or host:port addresses of replicas, which share
+	// the primary's other settings.
+	Hosts []string `yaml:"hosts"`
+	// HealthInterval is how often replicas are checked.
+	HealthInterval time.Duration `yaml:"health_interval"`
+	// MaxLag ejects replicas replaying further behind, zero for no limit.
+	MaxLag time.Duration `yaml:"max_lag"`
+}
+
+// primaryKey is the context key of WithPrimary.
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary, so they
+// see writes just made.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// usesPrimary reports whether ctx came from WithPrimary.
+func usesPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// replica is one replica pool and its health.
+type replica struct {
+	db      *sqlx.DB
+	host    string
+	healthy atomic.Bool
+}
+
+// Replicas routes reads round robin over healthy read replicas.
+type Replicas struct {
+	replicas []*replica
+	next     atomic.Uint64
+	cfg      ReplicaConfig
+	log      *zap.Logger
+}
+
+// OpenReplicas configures a pool per replica. Replicas are checked as
+// the app starts and then every health_interval, and those failing are
+// skipped until they pass again. Without replicas every read goes to
+// the primary.
+func OpenReplicas(p Params) (*Replicas, error) {
+	pcfg := Config{
+		Replicas: ReplicaConfig{
+			HealthInterval: 5 * time.Second,
+		},
+		Instrument: defaultInstrument,
+	}
+	err := p.Cfg.Get("postgres").Populate(&pcfg)
+	if err != nil {
+		return nil, fmt.Errorf("postgres config %w", err)
+	}
+	r := &Replicas{
+		cfg: pcfg.Replicas,
+		log: p.Log.Named("replicas"),
+	}
+	if len(r.cfg.Hosts) == 0 {
+		return r, nil
+	}
+	if r.cfg.HealthInterval <= 0 {
+		return nil, fmt.Errorf("postgres replicas health_interval must be positive")
+	}
+
+	for _, hostPort := range r.cfg.Hosts {
+		rcfg := pcfg
+		rcfg.Host = hostPort
+		if host, port, err := net.SplitHostPort(hostPort); err == nil {
+			rcfg.Host = host
+			rcfg.Port, err = strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("postgres replica %q port %w", hostPort, err)
+			}
+		}
+		err = rcfg.validate()
+		if err != nil {
+			return nil, fmt.Errorf("postgres replica %q %w", hostPort, err)
+		}
+
+		db, err := openDB(rcfg, hostPort, p.Stats, p.Log)
+		if err != nil {
+			return nil, err
+		}
+		r.replicas = append(r.replicas, &replica{db: db, host: hostPort})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.Lc.Append(fx.Hook{
+		OnStart: func(startCtx context.Context) error {
+			// Replicas down at start are skipped, not fatal.
+			r.check(startCtx)
+			go func() {
+				defer close(done)
+				r.watch(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			<-done
+			var errs []error
+			for _, rep := range r.replicas {
+				errs = append(errs, rep.db.Close())
+			}
+			return errors.Join(errs...)
+		},
+	})
+
+	return r, nil
+}
+
+// pick returns the next healthy replica, nil when there is none or
+// ctx reads from the primary.
+func (r *Replicas) pick(ctx context.Context) *replica {
+	if r == nil || len(r.replicas) == 0 || usesPrimary(ctx) {
+		return nil
+	}
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		rep := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// eject skips rep until its next passing health check.
+func (r *Replicas) eject(rep *replica, reason error) {
+	if rep.healthy.Swap(false) {
+		r.log.Warn("replica ejected", zap.String("host", rep.host), zap.Error(reason))
+	}
+}
+
+// admit routes reads to rep again.
+func (r *Replicas) admit(rep *replica) {
+	if !rep.healthy.Swap(true) {
+		r.log.Info("replica healthy", zap.String("host", rep.host))
+	}
+}
+
+// watch checks replicas every health interval until ctx is canceled.
+func (r *Replicas) watch(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.check(ctx)
+		}
+	}
+}
+
+// check admits replicas that answer within the health interval, are
+// streaming from the primary and replay within MaxLag, and ejects the
+// rest.
+func (r *Replicas) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, rep := range r.replicas {
+		wg.Add(1)
+		go func(rep *replica) {
+			defer wg.Done()
+			err := r.probe(ctx, rep)
+			if err != nil {
+				r.eject(rep, err)
+				return
+			}
+			r.admit(rep)
+		}(rep)
+	}
+	wg.Wait()
+}
+
+// replicaStatus is what a health check reads from a replica.
+type replicaStatus struct {
+	// Recovery is false on a primary, or a replica since promoted.
+	Recovery bool `db:"recovery"`
+	// Receiving is whether a WAL receiver process runs.
+	Receiving bool `db:"receiving"`
+	// Status is the WAL receiver state, null without pg_read_all_stats.
+	Status sql.NullString `db:"status"`
+	// Lag is how far replay is behind, in seconds.
+	Lag float64 `db:"lag"`
+}
+
+// probe checks one replica is reachable and caught up.
+func (r *Replicas) probe(ctx context.Context, rep *replica) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.HealthInterval)
+	defer cancel()
+
+	// Lag is zero while nothing is left to replay, however long ago
+	// the last transaction was.
+	var st replicaStatus
+	err := rep.db.GetContext(ctx, &st, `SELECT
+		pg_is_in_recovery() AS "recovery",
+		EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE pid IS NOT NULL) AS "receiving",
+		(SELECT status FROM pg_stat_wal_receiver) AS "status",
+		CASE
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE coalesce(extract(epoch FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END AS "lag"`)
+	if err != nil {
+		return fmt.Errorf("health check %w", err)
+	}
+	return r.healthy(st)
+}
+
+// healthy returns why a replica in st should not serve reads, nil
+// when it should. A replica not streaming from the primary may be
+// serving stale data however small its lag looks.
+func (r *Replicas) healthy(st replicaStatus) error {
+	if !st.Recovery {
+		return errors.New("not in recovery, a primary is listed as a replica")
+	}
+	if !st.Receiving {
+		return errors.New("no WAL receiver, not replicating from the primary")
+	}
+	if st.Status.Valid && st.Status.String != "streaming" {
+		return fmt.Errorf("WAL receiver %s, not streaming", st.Status.String)
+	}
+	if r.cfg.MaxLag > 0 && st.Lag > r.cfg.MaxLag.Seconds() {
+		return fmt.Errorf("replication lag %.1fs exceeds %s", st.Lag, r.cfg.MaxLag)
+	}
+	return nil
+}
+
+// read runs fn with a healthy replica, or with the primary when there
+// is none or ctx reads from it. A replica that cannot be reached is
+// ejected and fn runs again with the primary, so fn must reset any
+// partial results.
+func (g *gateway) read(ctx context.Context, fn func(db *sqlx.DB) error) error {
+	rep := g.replicas.pick(ctx)
+	if rep == nil {
+		return fn(g.db)
+	}
+
+	err := fn(rep.db)
+	if err == nil || ctx.Err() != nil || !isConnectionError(err) {
+		return err
+	}
+	g.replicas.eject(rep, err)
+	return fn(g.db)
+}
+
+// connectionCodes are SQLSTATEs of servers shutting down or starting.
+var connectionCodes = map[pq.ErrorCode]bool{
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// isConnectionError reports whether err means the server could not be
+// reached or is shutting down, rather than the query failing.
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Class() == "08" || connectionCodes[pqErr.Code]
+	}
+	return false
+}
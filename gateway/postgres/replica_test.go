@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+func TestReplicaHealthy(t *testing.T) {
+	r := &Replicas{cfg: ReplicaConfig{MaxLag: 10 * time.Second}}
+	streaming := sql.NullString{String: "streaming", Valid: true}
+
+	tests := []struct {
+		name    string
+		st      replicaStatus
+		healthy bool
+	}{
+		{"streaming", replicaStatus{Recovery: true, Receiving: true, Status: streaming}, true},
+		{"status hidden", replicaStatus{Recovery: true, Receiving: true}, true},
+		{"lagging within limit", replicaStatus{Recovery: true, Receiving: true, Status: streaming, Lag: 9}, true},
+		{"lagging", replicaStatus{Recovery: true, Receiving: true, Status: streaming, Lag: 11}, false},
+		{"primary", replicaStatus{Recovery: false}, false},
+		{"no receiver", replicaStatus{Recovery: true, Receiving: false}, false},
+		{"receiver stopping", replicaStatus{
+			Recovery:  true,
+			Receiving: true,
+			Status:    sql.NullString{String: "stopping", Valid: true},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.healthy(tt.st)
+			if (err == nil) != tt.healthy {
+				t.Fatalf("healthy: got %v, want healthy %t", err, tt.healthy)
+			}
+		})
+	}
+}
+
+// countConnector opens connections answering queries with no rows, or
+// with err when set. queries counts the queries run.
+type countConnector struct {
+	queries *atomic.Int32
+	err     error
+}
+
+func (c countConnector) Connect(context.Context) (driver.Conn, error) {
+	return countConn(c), nil
+}
+
+func (c countConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// countConn is a connection of countConnector.
+type countConn countConnector
+
+func (c countConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.queries.Add(1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &echoRows{done: true}, nil
+}
+
+func (c countConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c countConn) Close() error {
+	return nil
+}
+
+func (c countConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("begin not supported")
+}
+
+func TestSelectPlaceByFilterReadsReplica(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		replicaErr error
+		replica    int32
+		primary    int32
+	}{
+		{"healthy replica", context.Background(), nil, 1, 0},
+		{"with primary", WithPrimary(context.Background()), nil, 0, 1},
+		{"replica down", context.Background(), &net.OpError{Op: "read", Err: errors.New("connection reset")}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var primary, replicaQueries atomic.Int32
+			rep := &replica{
+				db: sqlx.NewDb(sql.OpenDB(countConnector{
+					queries: &replicaQueries,
+					err:     tt.replicaErr,
+				}), "postgres"),
+				host: "replica",
+			}
+			defer rep.db.Close()
+			rep.healthy.Store(true)
+			g := &gateway{
+				db:       sqlx.NewDb(sql.OpenDB(countConnector{queries: &primary}), "postgres"),
+				replicas: &Replicas{replicas: []*replica{rep}, log: zap.NewNop()},
+				log:      zap.NewNop(),
+			}
+			defer g.db.Close()
+
+			_, _, err := g.SelectPlaceByFilter(tt.ctx, Filter{}, Page{Size: 10})
+			if err != nil {
+				t.Fatalf("SelectPlaceByFilter: %v", err)
+			}
+			if got := replicaQueries.Load(); got != tt.replica {
+				t.Fatalf("replica ran %d queries, want %d", got, tt.replica)
+			}
+			if got := primary.Load(); got != tt.primary {
+				t.Fatalf("primary ran %d queries, want %d", got, tt.primary)
+			}
+			if rep.healthy.Load() != (tt.replicaErr == nil) {
+				t.Fatalf("replica healthy %t after error %v", rep.healthy.Load(), tt.replicaErr)
+			}
+		})
+	}
+}
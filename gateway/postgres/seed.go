@@ -0,0 +1,455 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// fixtureNamespace derives row ids from fixture names, so seeding a
+// set again updates its rows instead of adding more.
+var fixtureNamespace = uuid.MustParse("0b6c8c1e-3f6a-4f57-9a43-2d2f1c7e5b90")
+
+// fixtureSetName matches fixture set names, which are file names.
+var fixtureSetName = regexp.MustCompile(`^[\w-]+$`)
+
+// seedTables are emptied by Reset, referencing tables first.
+var seedTables = []string{"relationship", "fact", "place", "person"}
+
+// SeedConfig defines seeding settings under the seed key.
+type SeedConfig struct {
+	// Dir holds fixture sets, one <set>.yaml file per set.
+	Dir string `yaml:"dir"`
+	// Env selects the environment seeded by default.
+	Env string `yaml:"env"`
+	// Environments lists the sets seeded per environment.
+	Environments map[string][]string `yaml:"environments"`
+}
+
+// fixtureFile is a fixture set. Records are keyed by fixture name,
+// which other records refer to them by.
+type fixtureFile struct {
+	// Include names sets loaded along with this one.
+	Include       []string                       `yaml:"include"`
+	People        map[string]personFixture       `yaml:"people"`
+	Places        map[string]placeFixture        `yaml:"places"`
+	Facts         map[string]factFixture         `yaml:"facts"`
+	Relationships map[string]relationshipFixture `yaml:"relationships"`
+}
+
+// personFixture is a person record. Timestamps default to seed time.
+type personFixture struct {
+	FirstName string `yaml:"first_name"`
+	LastName  string `yaml:"last_name"`
+	Email     string `yaml:"email"`
+	Timestamp int64  `yaml:"timestamp"`
+}
+
+// placeFixture is a place record.
+type placeFixture struct {
+	Country   string           `yaml:"country"`
+	City      *string          `yaml:"city"`
+	Comments  []commentFixture `yaml:"comments"`
+	TelCode   int              `yaml:"telcode"`
+	Timestamp int64            `yaml:"timestamp"`
+}
+
+// commentFixture is a place comment, its id derived from its position.
+type commentFixture struct {
+	Author    string            `yaml:"author"`
+	Text      string            `yaml:"text"`
+	Fields    map[string]string `yaml:"fields"`
+	CreatedAt int64             `yaml:"created_at"`
+}
+
+// factFixture is a fact record, naming the person and place it refers to.
+type factFixture struct {
+	Fact      string `yaml:"fact"`
+	Person    string `yaml:"person"`
+	Place     string `yaml:"place"`
+	Timestamp int64  `yaml:"timestamp"`
+}
+
+// relationshipFixture is a relationship record, dates as YYYY-MM-DD.
+type relationshipFixture struct {
+	Person    string `yaml:"person"`
+	Place     string `yaml:"place"`
+	Role      Role   `yaml:"role"`
+	StartDate string `yaml:"start_date"`
+	EndDate   string `yaml:"end_date"`
+	Timestamp int64  `yaml:"timestamp"`
+}
+
+// Fixtures are the resolved rows of fixture sets.
+type Fixtures struct {
+	People        []Person
+	Places        []Place
+	Facts         []Fact
+	Relationships []Relationship
+}
+
+// Seeder loads fixture sets into the database.
+type Seeder struct {
+	db  *sqlx.DB
+	cfg SeedConfig
+	log *zap.Logger
+}
+
+// SeederParams defines NewSeeder requirements.
+type SeederParams struct {
+	fx.In
+
+	DB  *sqlx.DB
+	Cfg config.Provider
+	Log *zap.Logger
+}
+
+// NewSeeder is the Seeder constructor.
+func NewSeeder(p SeederParams) (*Seeder, error) {
+	var cfg SeedConfig
+	err := p.Cfg.Get("seed").Populate(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("seed config %w", err)
+	}
+
+	return &Seeder{
+		db:  p.DB,
+		cfg: cfg,
+		log: p.Log,
+	}, nil
+}
+
+// EnvSets returns the sets seeded for env, the configured one when empty.
+func (s *Seeder) EnvSets(env string) ([]string, error) {
+	if env == "" {
+		env = s.cfg.Env
+	}
+	sets, ok := s.cfg.Environments[env]
+	if !ok {
+		return nil, fmt.Errorf("no fixture sets for environment %q", env)
+	}
+	return sets, nil
+}
+
+// Load reads sets and the sets they include and resolves references.
+// A fixture name may be defined by one set only.
+func (s *Seeder) Load(sets ...string) (Fixtures, error) {
+	merged := &fixtureFile{
+		People:        make(map[string]personFixture),
+		Places:        make(map[string]placeFixture),
+		Facts:         make(map[string]factFixture),
+		Relationships: make(map[string]relationshipFixture),
+	}
+	loaded := make(map[string]bool)
+	for _, set := range sets {
+		err := s.loadSet(set, merged, loaded, nil)
+		if err != nil {
+			return Fixtures{}, err
+		}
+	}
+
+	return merged.resolve(time.Now().UTC().Unix())
+}
+
+// Seed upserts the fixtures of sets in one transaction.
+func (s *Seeder) Seed(ctx context.Context, sets ...string) error {
+	return s.seed(ctx, false, sets)
+}
+
+// Reset empties the seeded tables and seeds sets in one transaction,
+// leaving integration tests a known state.
+func (s *Seeder) Reset(ctx context.Context, sets ...string) error {
+	return s.seed(ctx, true, sets)
+}
+
+// seed writes the fixtures of sets, first emptying tables on reset.
+func (s *Seeder) seed(ctx context.Context, reset bool, sets []string) error {
+	fixtures, err := s.Load(sets...)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BeginTxx %w", err)
+	}
+	defer tx.Rollback()
+
+	if reset {
+		tables := make([]string, len(seedTables))
+		for i, table := range seedTables {
+			tables[i] = pq.QuoteIdentifier(table)
+		}
+		_, err = tx.ExecContext(ctx, "TRUNCATE "+strings.Join(tables, ", "))
+		if err != nil {
+			return fmt.Errorf("truncate %w", err)
+		}
+	}
+
+	// People and places go first, facts and relationships refer to them.
+	for _, p := range fixtures.People {
+		err = upsert(ctx, tx, "person", p)
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range fixtures.Places {
+		err = upsert(ctx, tx, "place", p)
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range fixtures.Facts {
+		err = upsert(ctx, tx, "fact", f)
+		if err != nil {
+			return err
+		}
+	}
+	for _, r := range fixtures.Relationships {
+		err = upsert(ctx, tx, "relationship", r)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Commit %w", err)
+	}
+
+	s.log.Info("seeded fixtures",
+		zap.Strings("sets", sets),
+		zap.Bool("reset", reset),
+		zap.Int("people", len(fixtures.People)),
+		zap.Int("places", len(fixtures.Places)),
+		zap.Int("facts", len(fixtures.Facts)),
+		zap.Int("relationships", len(fixtures.Relationships)),
+	)
+	return nil
+}
+
+// loadSet merges set and its includes into merged, once per set.
+// stack holds the sets including it, to reject include cycles.
+func (s *Seeder) loadSet(
+	set string,
+	merged *fixtureFile,
+	loaded map[string]bool,
+	stack []string,
+) error {
+	for _, name := range stack {
+		if name == set {
+			return fmt.Errorf("fixture include cycle %s", strings.Join(append(stack, set), " -> "))
+		}
+	}
+	if loaded[set] {
+		return nil
+	}
+	if !fixtureSetName.MatchString(set) {
+		return fmt.Errorf("invalid fixture set name %q", set)
+	}
+
+	provider, err := config.NewYAML(config.File(filepath.Join(s.cfg.Dir, set+".yaml")))
+	if err != nil {
+		return fmt.Errorf("fixture set %s %w", set, err)
+	}
+	var f fixtureFile
+	err = provider.Get(config.Root).Populate(&f)
+	if err != nil {
+		return fmt.Errorf("fixture set %s %w", set, err)
+	}
+
+	for _, include := range f.Include {
+		err = s.loadSet(include, merged, loaded, append(stack, set))
+		if err != nil {
+			return err
+		}
+	}
+	loaded[set] = true
+
+	for name, p := range f.People {
+		if _, ok := merged.People[name]; ok {
+			return fmt.Errorf("fixture set %s redefines people.%s", set, name)
+		}
+		merged.People[name] = p
+	}
+	for name, p := range f.Places {
+		if _, ok := merged.Places[name]; ok {
+			return fmt.Errorf("fixture set %s redefines places.%s", set, name)
+		}
+		merged.Places[name] = p
+	}
+	for name, fact := range f.Facts {
+		if _, ok := merged.Facts[name]; ok {
+			return fmt.Errorf("fixture set %s redefines facts.%s", set, name)
+		}
+		merged.Facts[name] = fact
+	}
+	for name, r := range f.Relationships {
+		if _, ok := merged.Relationships[name]; ok {
+			return fmt.Errorf("fixture set %s redefines relationships.%s", set, name)
+		}
+		merged.Relationships[name] = r
+	}
+	return nil
+}
+
+// resolve converts fixtures to rows in name order, replacing names
+// with ids. Unset timestamps become now.
+func (f *fixtureFile) resolve(now int64) (Fixtures, error) {
+	var out Fixtures
+	stamp := func(ts int64) int64 {
+		if ts == 0 {
+			return now
+		}
+		return ts
+	}
+
+	for _, name := range sortedNames(f.People) {
+		p := f.People[name]
+		out.People = append(out.People, Person{
+			ID:        fixtureID("people", name),
+			FirstName: p.FirstName,
+			LastName:  p.LastName,
+			Email:     p.Email,
+			Timestamp: stamp(p.Timestamp),
+		})
+	}
+
+	for _, name := range sortedNames(f.Places) {
+		p := f.Places[name]
+		place := Place{
+			ID:        fixtureID("places", name),
+			Country:   p.Country,
+			Comments:  Comments{},
+			TelCode:   p.TelCode,
+			Timestamp: stamp(p.Timestamp),
+		}
+		for i, c := range p.Comments {
+			place.Comments = append(place.Comments, Comment{
+				ID:        fixtureID("comments", fmt.Sprintf("%s/%d", name, i)),
+				Author:    c.Author,
+				Text:      c.Text,
+				Fields:    c.Fields,
+				CreatedAt: stamp(c.CreatedAt),
+			})
+		}
+		if p.City != nil {
+			place.City = sql.NullString{
+				String: *p.City,
+				Valid:  true,
+			}
+		}
+		out.Places = append(out.Places, place)
+	}
+
+	for _, name := range sortedNames(f.Facts) {
+		fact := f.Facts[name]
+		if fact.Fact == "" {
+			return Fixtures{}, fmt.Errorf("facts.%s: fact is required", name)
+		}
+		row := Fact{
+			ID:        fixtureID("facts", name),
+			Fact:      fact.Fact,
+			Timestamp: stamp(fact.Timestamp),
+		}
+		if fact.Person != "" {
+			if _, ok := f.People[fact.Person]; !ok {
+				return Fixtures{}, fmt.Errorf("facts.%s: unknown person %q", name, fact.Person)
+			}
+			row.PersonID = sql.NullString{
+				String: fixtureID("people", fact.Person),
+				Valid:  true,
+			}
+		}
+		if fact.Place != "" {
+			if _, ok := f.Places[fact.Place]; !ok {
+				return Fixtures{}, fmt.Errorf("facts.%s: unknown place %q", name, fact.Place)
+			}
+			row.PlaceID = sql.NullString{
+				String: fixtureID("places", fact.Place),
+				Valid:  true,
+			}
+		}
+		out.Facts = append(out.Facts, row)
+	}
+
+	for _, name := range sortedNames(f.Relationships) {
+		r := f.Relationships[name]
+		if _, ok := f.People[r.Person]; !ok {
+			return Fixtures{}, fmt.Errorf("relationships.%s: unknown person %q", name, r.Person)
+		}
+		if _, ok := f.Places[r.Place]; !ok {
+			return Fixtures{}, fmt.Errorf("relationships.%s: unknown place %q", name, r.Place)
+		}
+		if r.Role != RoleVisitor && r.Role != RoleResident {
+			return Fixtures{}, fmt.Errorf("relationships.%s: unknown role %q", name, r.Role)
+		}
+		row := Relationship{
+			ID:        fixtureID("relationships", name),
+			PersonID:  fixtureID("people", r.Person),
+			PlaceID:   fixtureID("places", r.Place),
+			Role:      r.Role,
+			Timestamp: stamp(r.Timestamp),
+		}
+		var err error
+		row.StartDate, err = time.Parse(time.DateOnly, r.StartDate)
+		if err != nil {
+			return Fixtures{}, fmt.Errorf("relationships.%s: start_date %w", name, err)
+		}
+		if r.EndDate != "" {
+			row.EndDate.Time, err = time.Parse(time.DateOnly, r.EndDate)
+			if err != nil {
+				return Fixtures{}, fmt.Errorf("relationships.%s: end_date %w", name, err)
+			}
+			row.EndDate.Valid = true
+		}
+		out.Relationships = append(out.Relationships, row)
+	}
+
+	return out, nil
+}
+
+// upsert writes row to name, replacing the row with the same id.
+// Seeding is not audited.
+func upsert(ctx context.Context, tx *sqlx.Tx, name string, row interface{}) error {
+	row, err := touch(row, time.Now().UTC().Unix(), true)
+	if err != nil {
+		return err
+	}
+	query, args, err := upsertQuery(name, row)
+	if err != nil {
+		return fmt.Errorf("upsertQuery %w", err)
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("upsert %s %w", name, err)
+	}
+	return nil
+}
+
+// fixtureID returns the stable id of a fixture.
+func fixtureID(kind, name string) string {
+	return uuid.NewSHA1(fixtureNamespace, []byte(kind+"/"+name)).String()
+}
+
+// sortedNames returns the keys of m in order.
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
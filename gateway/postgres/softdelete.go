@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"reflect"
+)
+
+// deletedColumn marks soft deleted rows of tables that have it.
+const deletedColumn = "deleted_at"
+
+// deletedKey is the context key of WithDeleted.
+type deletedKey struct{}
+
+// WithDeleted returns a context whose queries include soft deleted
+// rows. Rows related through a Subquery must still be live.
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedKey{}, true)
+}
+
+// includesDeleted reports whether ctx came from WithDeleted.
+func includesDeleted(ctx context.Context) bool {
+	deleted, _ := ctx.Value(deletedKey{}).(bool)
+	return deleted
+}
+
+// softDeletes reports whether t has a deleted_at column.
+func (t *table) softDeletes() bool {
+	_, ok := t.byName[deletedColumn]
+	return ok
+}
+
+// live matches rows that are not soft deleted.
+func live() Filter {
+	return Where(deletedColumn, OpIsNull, nil)
+}
+
+// visible narrows f to live rows of row's table, unless ctx includes
+// deleted rows or the table has no soft deletes.
+func visible(ctx context.Context, row interface{}, f Filter) (Filter, error) {
+	t, err := tableOf(reflect.Indirect(reflect.ValueOf(row)).Type())
+	if err != nil {
+		return Filter{}, err
+	}
+	if !t.softDeletes() || includesDeleted(ctx) {
+		return f, nil
+	}
+	return And(f, live()), nil
+}
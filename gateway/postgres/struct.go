@@ -2,8 +2,7 @@ package postgres
 
 import (
 	"database/sql"
-
-	"github.com/lib/pq"
+	"time"
 )
 
 // Person corresponds to the person table.
@@ -13,6 +12,12 @@ type Person struct {
 	LastName  string `json:"last_name,omitempty"  db:"last_name"`
 	Email     string `json:"email,omitempty"      db:"email"`
 	Timestamp int64  `json:"timestamp,omitempty"  db:"timestamp"`
+	// CreatedAt, UpdatedAt and DeletedAt are set by the gateway.
+	CreatedAt int64         `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt int64         `json:"updated_at,omitempty" db:"updated_at"`
+	DeletedAt sql.NullInt64 `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Version counts changes, from 1 on create.
+	Version int64 `json:"version,omitempty" db:"version"`
 }
 
 // Place corresponds to the place table.
@@ -20,7 +25,41 @@ type Place struct {
 	ID        string         `json:"id,omitempty"        db:"id"`
 	Country   string         `json:"country,omitempty"   db:"country"`
 	City      sql.NullString `json:"city,omitempty"      db:"city"`
-	Comments  pq.StringArray `json:"comments,omitempty"  db:"comments"`
+	Comments  Comments       `json:"comments,omitempty"  db:"comments"`
 	TelCode   int            `json:"telcode,omitempty"   db:"telcode"`
 	Timestamp int64          `json:"timestamp,omitempty" db:"timestamp"`
+	// CreatedAt, UpdatedAt and DeletedAt are set by the gateway.
+	CreatedAt int64         `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt int64         `json:"updated_at,omitempty" db:"updated_at"`
+	DeletedAt sql.NullInt64 `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Version counts changes, from 1 on create.
+	Version int64 `json:"version,omitempty" db:"version"`
+}
+
+// Fact corresponds to the fact table.
+type Fact struct {
+	ID        string         `json:"id,omitempty"        db:"id"`
+	Fact      string         `json:"fact,omitempty"      db:"fact"`
+	PersonID  sql.NullString `json:"person_id,omitempty" db:"person_id"`
+	PlaceID   sql.NullString `json:"place_id,omitempty"  db:"place_id"`
+	Timestamp int64          `json:"timestamp,omitempty" db:"timestamp"`
+}
+
+// Relationship corresponds to the relationship table, a person's
+// visit to or residence in a place.
+type Relationship struct {
+	ID       string `json:"id,omitempty"        db:"id"`
+	PersonID string `json:"person_id,omitempty" db:"person_id"`
+	PlaceID  string `json:"place_id,omitempty"  db:"place_id"`
+	Role     Role   `json:"role,omitempty"      db:"role"`
+	// StartDate and EndDate are dates, EndDate is null while ongoing.
+	StartDate time.Time    `json:"start_date"          db:"start_date"`
+	EndDate   sql.NullTime `json:"end_date"            db:"end_date"`
+	Timestamp int64        `json:"timestamp,omitempty" db:"timestamp"`
+	// CreatedAt, UpdatedAt and DeletedAt are set by the gateway.
+	CreatedAt int64         `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt int64         `json:"updated_at,omitempty" db:"updated_at"`
+	DeletedAt sql.NullInt64 `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Version counts changes, from 1 on create.
+	Version int64 `json:"version,omitempty" db:"version"`
 }
@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// slowConnector opens connections whose queries run until canceled, a
+// stand in for a slow statement. canceled counts canceled statements.
+type slowConnector struct {
+	canceled *atomic.Int32
+}
+
+func (c slowConnector) Connect(context.Context) (driver.Conn, error) {
+	return slowConn{canceled: c.canceled}, nil
+}
+
+func (c slowConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// slowConn answers queries like lib/pq does when their context ends.
+type slowConn struct {
+	canceled *atomic.Int32
+}
+
+func (c slowConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	select {
+	case <-ctx.Done():
+		c.canceled.Add(1)
+		return nil, &pq.Error{Code: queryCanceled, Message: "canceling statement due to user request"}
+	case <-time.After(5 * time.Second):
+		return nil, errors.New("statement not canceled")
+	}
+}
+
+func (c slowConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c slowConn) Close() error {
+	return nil
+}
+
+func (c slowConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("begin not supported")
+}
+
+// newSlowGateway returns a gateway over slowConnector.
+func newSlowGateway(queryTimeout time.Duration) (*gateway, *atomic.Int32) {
+	canceled := new(atomic.Int32)
+	db := sqlx.NewDb(sql.OpenDB(slowConnector{canceled: canceled}), "postgres")
+	return &gateway{
+		db:           db,
+		queryTimeout: queryTimeout,
+		log:          zap.NewNop(),
+	}, canceled
+}
+
+func TestQueryTimeoutCancelsStatement(t *testing.T) {
+	g, canceled := newSlowGateway(50 * time.Millisecond)
+
+	start := time.Now()
+	_, err := g.GetPerson(context.Background(), "id")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("GetPerson returned after %s, want the 50ms timeout", elapsed)
+	}
+	if !IsCanceled(err) {
+		t.Fatalf("GetPerson: got %v, want a canceled statement", err)
+	}
+	if canceled.Load() != 1 {
+		t.Fatalf("canceled statements: got %d, want 1", canceled.Load())
+	}
+}
+
+func TestCallerCancelCancelsStatement(t *testing.T) {
+	g, canceled := newSlowGateway(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := g.GetPerson(ctx, "id")
+	if !IsCanceled(err) {
+		t.Fatalf("GetPerson: got %v, want a canceled statement", err)
+	}
+	if canceled.Load() != 1 {
+		t.Fatalf("canceled statements: got %d, want 1", canceled.Load())
+	}
+}
+
+func TestIsCanceled(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{context.Canceled, true},
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("GetContext %w", context.DeadlineExceeded), true},
+		{&pq.Error{Code: queryCanceled}, true},
+		{fmt.Errorf("GetContext %w", &pq.Error{Code: queryCanceled}), true},
+		{&pq.Error{Code: "23505"}, false},
+		{sql.ErrNoRows, false},
+		{ErrNotFound, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsCanceled(tt.err); got != tt.want {
+			t.Errorf("IsCanceled(%v): got %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestQueryTimeoutPgSleep checks the server stops a slow statement, on
+// the database at POSTGRES_TEST_DSN, skipped when unset.
+func TestQueryTimeoutPgSleep(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN not set")
+	}
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+	g := &gateway{db: db, queryTimeout: 100 * time.Millisecond, log: zap.NewNop()}
+
+	ctx, cancel := g.timeout(context.Background())
+	defer cancel()
+	start := time.Now()
+	_, err = db.ExecContext(ctx, "SELECT pg_sleep(10), 'timeout_test'")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("pg_sleep returned after %s, want the 100ms timeout", elapsed)
+	}
+	if !IsCanceled(err) {
+		t.Fatalf("pg_sleep: got %v, want a canceled statement", err)
+	}
+
+	// The server no longer runs the statement.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var running int
+		err := db.Get(&running, `SELECT count(*) FROM pg_stat_activity
+			WHERE state = 'active' AND query LIKE '%''timeout_test''%' AND pid <> pg_backend_pid()`)
+		if err != nil {
+			t.Fatalf("pg_stat_activity: %v", err)
+		}
+		if running == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pg_sleep still running on the server")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
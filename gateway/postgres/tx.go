@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// SQLSTATEs worth retrying the whole transaction for.
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// TxConfig defines transaction retry settings under postgres.tx.
+type TxConfig struct {
+	// MaxAttempts includes the first attempt.
+	MaxAttempts int           `yaml:"max_attempts"`
+	Backoff     time.Duration `yaml:"backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+}
+
+// WithTx runs fn in a transaction, committing when it returns nil and
+// rolling back on error or panic. Nil opts use serializable isolation.
+// Serialization failures and deadlocks are retried with backoff, so fn
+// may run more than once and must not have side effects outside tx.
+func (g *gateway) WithTx(
+	ctx context.Context,
+	opts *sql.TxOptions,
+	fn func(tx *sqlx.Tx) error,
+) error {
+	if opts == nil {
+		opts = g.txOpts
+	}
+	return g.retryTx(ctx, func() error {
+		return g.runTx(ctx, opts, fn)
+	})
+}
+
+// retryTx calls run until it succeeds, fails with an error not worth
+// retrying, MaxAttempts are made or ctx is done.
+func (g *gateway) retryTx(ctx context.Context, run func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || !retryableTx(err) || attempt >= g.txCfg.MaxAttempts {
+			return err
+		}
+
+		// Full jitter keeps retrying writers from colliding again.
+		wait := time.Duration(rand.Int63n(int64(g.txCfg.backoff(attempt)) + 1))
+		g.log.Warn("retrying transaction",
+			zap.Int("attempt", attempt),
+			zap.Duration("wait", wait),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// backoff returns the longest wait after attempt fails, doubling from
+// Backoff up to MaxBackoff.
+func (c TxConfig) backoff(attempt int) time.Duration {
+	wait := c.Backoff
+	for i := 1; i < attempt && wait < c.MaxBackoff; i++ {
+		wait = min(wait*2, c.MaxBackoff)
+	}
+	return wait
+}
+
+// runTx makes a single transaction attempt.
+func (g *gateway) runTx(
+	ctx context.Context,
+	opts *sql.TxOptions,
+	fn func(tx *sqlx.Tx) error,
+) (err error) {
+	tx, err := g.db.BeginTxx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("BeginTxx %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("Commit %w", err)
+	}
+
+	return nil
+}
+
+// retryableTx reports whether err is a serialization failure or deadlock.
+func retryableTx(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+}
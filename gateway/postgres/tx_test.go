@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// newRetryGateway returns a gateway retrying transactions per cfg.
+func newRetryGateway(cfg TxConfig) *gateway {
+	return &gateway{txCfg: cfg, log: zap.NewNop()}
+}
+
+func TestRetryTx(t *testing.T) {
+	serialization := &pq.Error{Code: serializationFailure}
+	deadlock := &pq.Error{Code: deadlockDetected}
+	unique := &pq.Error{Code: "23505"}
+	other := errors.New("boom")
+
+	tests := []struct {
+		name  string
+		errs  []error
+		calls int
+		err   error
+	}{
+		{"success", []error{nil}, 1, nil},
+		{"serialization failure", []error{serialization, nil}, 2, nil},
+		{"deadlock", []error{deadlock, deadlock, nil}, 3, nil},
+		{"wrapped serialization failure", []error{fmt.Errorf("Commit %w", serialization), nil}, 2, nil},
+		{"max attempts", []error{serialization, serialization, serialization, nil}, 3, serialization},
+		{"other sqlstate", []error{unique, nil}, 1, unique},
+		{"other error", []error{other, nil}, 1, other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newRetryGateway(TxConfig{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+			calls := 0
+			err := g.retryTx(context.Background(), func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("retryTx: got %v, want %v", err, tt.err)
+			}
+			if calls != tt.calls {
+				t.Fatalf("run called %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryTxStopsWhenCanceled(t *testing.T) {
+	g := newRetryGateway(TxConfig{MaxAttempts: 3, Backoff: time.Hour, MaxBackoff: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	serialization := &pq.Error{Code: serializationFailure}
+
+	calls := 0
+	start := time.Now()
+	err := g.retryTx(ctx, func() error {
+		calls++
+		cancel()
+		return serialization
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("retryTx returned after %s, want no wait once canceled", elapsed)
+	}
+	if !errors.Is(err, serialization) || calls != 1 {
+		t.Fatalf("retryTx: got %v after %d calls, want the failure after 1", err, calls)
+	}
+}
+
+func TestTxBackoff(t *testing.T) {
+	cfg := TxConfig{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+		{4, 50 * time.Millisecond},
+		{100, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := cfg.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestWithTxRetriesTransaction(t *testing.T) {
+	g := newRetryGateway(TxConfig{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	g.db = sqlx.NewDb(sql.OpenDB(echoConnector{}), "postgres")
+	defer g.db.Close()
+
+	calls := 0
+	err := g.WithTx(context.Background(), &sql.TxOptions{}, func(*sqlx.Tx) error {
+		calls++
+		if calls == 1 {
+			return &pq.Error{Code: serializationFailure}
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("WithTx: got %v after %d attempts, want success after 2", err, calls)
+	}
+}
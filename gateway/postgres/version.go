@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// versionColumn counts the changes to rows of tables that have it.
+const versionColumn = "version"
+
+// ErrVersionMismatch is returned when a row changed since the version
+// a change was conditioned on.
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// versionKey is the context key of IfVersion.
+type versionKey struct{}
+
+// IfVersion returns a context whose updates and deletes fail with
+// ErrVersionMismatch unless the row is at version. Zero leaves them
+// unconditional.
+func IfVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+// expectedVersion returns the version of ctx, zero when unset.
+func expectedVersion(ctx context.Context) int64 {
+	version, _ := ctx.Value(versionKey{}).(int64)
+	return version
+}
+
+// checkVersion compares the version of a row snapshot of table to the
+// one ctx expects.
+func checkVersion(ctx context.Context, table, id string, row RawJSON) error {
+	want := expectedVersion(ctx)
+	if want == 0 {
+		return nil
+	}
+
+	var current struct {
+		Version *int64 `json:"version"`
+	}
+	err := json.Unmarshal(row, &current)
+	if err != nil {
+		return fmt.Errorf("version %w", err)
+	}
+	if current.Version == nil {
+		return fmt.Errorf("%w: %s has no version column", ErrInvalidFilter, table)
+	}
+	if *current.Version != want {
+		return fmt.Errorf(
+			"%s %s %w: at version %d, not %d",
+			table,
+			id,
+			ErrVersionMismatch,
+			*current.Version,
+			want,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// Topology modes selectable with redis.mode.
+const (
+	ModeSingle   = "single"
+	ModeSentinel = "sentinel"
+	ModeCluster  = "cluster"
+)
+
+// Config defines the redis connection settings under the redis key.
+type Config struct {
+	// Mode is one of single, sentinel or cluster.
+	Mode string `yaml:"mode"`
+	// Addresses of the server, sentinels or cluster seed nodes.
+	Addresses []string `yaml:"addresses"`
+	// MasterName is the sentinel monitored master, sentinel mode only.
+	MasterName       string `yaml:"master_name"`
+	Username         string `yaml:"username"`
+	Password         string `yaml:"password"`
+	SentinelUsername string `yaml:"sentinel_username"`
+	SentinelPassword string `yaml:"sentinel_password"`
+	// DB index, must be zero in cluster mode.
+	DB           int           `yaml:"db"`
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	Pool         PoolConfig    `yaml:"pool"`
+	TLS          TLSConfig     `yaml:"tls"`
+	IAM          IAMConfig     `yaml:"iam"`
+}
+
+// PoolConfig defines connection pool settings. Zero values use go-redis defaults.
+type PoolConfig struct {
+	Size            int           `yaml:"size"`
+	MinIdle         int           `yaml:"min_idle"`
+	MaxIdle         int           `yaml:"max_idle"`
+	Timeout         time.Duration `yaml:"timeout"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// TLSConfig defines transport security settings.
+type TLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CAFile is a PEM bundle used instead of the system roots.
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile are an optional client certificate pair.
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// options converts the config to go-redis universal options.
+func (c Config) options() (*redis.UniversalOptions, error) {
+	if len(c.Addresses) == 0 {
+		return nil, fmt.Errorf("redis addresses required")
+	}
+
+	switch c.Mode {
+	case ModeSingle, "":
+		if len(c.Addresses) > 1 {
+			return nil, fmt.Errorf("single mode takes one address, got %d", len(c.Addresses))
+		}
+	case ModeSentinel:
+		if c.MasterName == "" {
+			return nil, fmt.Errorf("sentinel mode requires master_name")
+		}
+	case ModeCluster:
+		if c.DB != 0 {
+			return nil, fmt.Errorf("cluster mode only supports db 0")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported redis mode %q", c.Mode)
+	}
+
+	// ElastiCache only accepts IAM auth over encrypted connections.
+	if c.IAM.Enabled && !c.TLS.Enabled {
+		return nil, fmt.Errorf("iam auth requires tls")
+	}
+
+	tlsConfig, err := c.TLS.build()
+	if err != nil {
+		return nil, fmt.Errorf("redis tls %w", err)
+	}
+
+	return &redis.UniversalOptions{
+		Addrs:            c.Addresses,
+		MasterName:       c.MasterName,
+		Username:         c.Username,
+		Password:         c.Password,
+		SentinelUsername: c.SentinelUsername,
+		SentinelPassword: c.SentinelPassword,
+		DB:               c.DB,
+		DialTimeout:      c.DialTimeout,
+		ReadTimeout:      c.ReadTimeout,
+		WriteTimeout:     c.WriteTimeout,
+		PoolSize:         c.Pool.Size,
+		MinIdleConns:     c.Pool.MinIdle,
+		MaxIdleConns:     c.Pool.MaxIdle,
+		PoolTimeout:      c.Pool.Timeout,
+		ConnMaxIdleTime:  c.Pool.ConnMaxIdleTime,
+		ConnMaxLifetime:  c.Pool.ConnMaxLifetime,
+		TLSConfig:        tlsConfig,
+	}, nil
+}
+
+// build returns the tls config, nil when TLS is disabled.
+func (c TLSConfig) build() (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CAFile != "" {
+		caBytes, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load key pair %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
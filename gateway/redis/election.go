@@ -0,0 +1,199 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// ElectionConfig defines leader election settings under the election key.
+type ElectionConfig struct {
+	// Name is the lock replicas campaign for.
+	Name string `yaml:"name"`
+	// TTL is the lease length, renewed every third of it.
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// Elector campaigns for leadership by holding a renewed lease,
+// so only one replica runs background work at a time.
+type Elector struct {
+	cache Gateway
+	log   *zap.Logger
+	cfg   ElectionConfig
+
+	mu        sync.RWMutex
+	leader    bool
+	lease     Lease
+	renewed   time.Time
+	observers []func(leader bool)
+}
+
+// ElectorParams defines constructor requirements.
+type ElectorParams struct {
+	fx.In
+
+	Cache Gateway
+	Cfg   config.Provider
+	Log   *zap.Logger
+	Lc    fx.Lifecycle
+}
+
+// NewElector is the Elector constructor. Campaigning starts with the app.
+func NewElector(p ElectorParams) (*Elector, error) {
+	ecfg := ElectionConfig{
+		Name: "leader",
+		TTL:  15 * time.Second,
+	}
+	err := p.Cfg.Get("election").Populate(&ecfg)
+	if err != nil {
+		return nil, fmt.Errorf("election config %w", err)
+	}
+	if ecfg.TTL <= 0 {
+		return nil, fmt.Errorf("election ttl must be positive")
+	}
+
+	e := &Elector{
+		cache: p.Cache,
+		log:   p.Log.With(zap.String("election", ecfg.Name)),
+		cfg:   ecfg,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.Lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				e.run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			<-done
+			e.resign(stopCtx)
+			return nil
+		},
+	})
+
+	return e, nil
+}
+
+// IsLeader reports whether this instance currently holds leadership.
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Fence returns the fencing token of the current term, zero when not leader.
+func (e *Elector) Fence() int64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lease.Fence
+}
+
+// OnChange registers fn to be called whenever leadership is gained or lost.
+func (e *Elector) OnChange(fn func(leader bool)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.observers = append(e.observers, fn)
+}
+
+// run campaigns until ctx is canceled.
+func (e *Elector) run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.TTL / 3)
+	defer ticker.Stop()
+
+	e.campaign(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.campaign(ctx)
+		}
+	}
+}
+
+// campaign renews a held lease or tries to acquire a free one.
+func (e *Elector) campaign(ctx context.Context) {
+	e.mu.RLock()
+	leader, lease, renewed := e.leader, e.lease, e.renewed
+	e.mu.RUnlock()
+
+	if leader {
+		err := e.cache.Renew(ctx, lease, e.cfg.TTL)
+		switch {
+		case err == nil:
+			e.mu.Lock()
+			e.renewed = time.Now()
+			e.mu.Unlock()
+			return
+		case errors.Is(err, ErrLeaseLost):
+			e.setLeader(false, Lease{})
+		default:
+			e.log.Error("renew leadership", zap.Error(err))
+			// Step down once the lease may have lapsed elsewhere.
+			if time.Since(renewed) >= e.cfg.TTL {
+				e.setLeader(false, Lease{})
+			}
+			return
+		}
+	}
+
+	lease, err := e.cache.Acquire(ctx, e.cfg.Name, e.cfg.TTL)
+	if err != nil {
+		if !errors.Is(err, ErrNotAcquired) && ctx.Err() == nil {
+			e.log.Error("acquire leadership", zap.Error(err))
+		}
+		return
+	}
+	e.setLeader(true, lease)
+}
+
+// resign releases leadership so another replica can take over quickly.
+func (e *Elector) resign(ctx context.Context) {
+	e.mu.RLock()
+	leader, lease := e.leader, e.lease
+	e.mu.RUnlock()
+	if !leader {
+		return
+	}
+
+	err := e.cache.Release(ctx, lease)
+	if err != nil && !errors.Is(err, ErrLeaseLost) {
+		e.log.Error("release leadership", zap.Error(err))
+	}
+	e.setLeader(false, Lease{})
+}
+
+// setLeader records a leadership change and notifies observers.
+func (e *Elector) setLeader(leader bool, lease Lease) {
+	e.mu.Lock()
+	changed := e.leader != leader
+	e.leader = leader
+	e.lease = lease
+	e.renewed = time.Now()
+	observers := e.observers
+	e.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if leader {
+		e.log.Info("leadership acquired", zap.Int64("fence", lease.Fence))
+	} else {
+		e.log.Info("leadership lost")
+	}
+	for _, fn := range observers {
+		fn(leader)
+	}
+}
@@ -0,0 +1,161 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// iamTokenTTL is how long ElastiCache accepts a presigned token.
+	iamTokenTTL = 15 * time.Minute
+	// iamRefreshWindow regenerates tokens this long before they expire.
+	iamRefreshWindow = 5 * time.Minute
+	iamService       = "elasticache"
+)
+
+// emptyPayloadHash is the sha256 of an empty request body.
+var emptyPayloadHash = func() string {
+	sum := sha256.Sum256(nil)
+	return hex.EncodeToString(sum[:])
+}()
+
+// IAMConfig defines ElastiCache IAM authentication settings.
+type IAMConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// ReplicationGroupID is the replication group, or cache name when serverless.
+	ReplicationGroupID string `yaml:"replication_group_id"`
+	// UserID is the ElastiCache user, also sent as the AUTH username.
+	UserID     string `yaml:"user_id"`
+	Serverless bool   `yaml:"serverless"`
+}
+
+// IAMTokenGenerator creates ElastiCache IAM auth tokens.
+// Tokens are cached and regenerated before their 15 minute expiry.
+type IAMTokenGenerator struct {
+	mu      sync.Mutex
+	creds   aws.CredentialsProvider
+	signer  *v4.Signer
+	region  string
+	cfg     IAMConfig
+	log     *zap.Logger
+	now     func() time.Time
+	token   string
+	expires time.Time
+}
+
+// NewIAMTokenGenerator is the IAMTokenGenerator constructor.
+func NewIAMTokenGenerator(acfg aws.Config, cfg IAMConfig, log *zap.Logger) (*IAMTokenGenerator, error) {
+	if cfg.ReplicationGroupID == "" || cfg.UserID == "" {
+		return nil, fmt.Errorf("iam auth requires replication_group_id and user_id")
+	}
+	if acfg.Credentials == nil {
+		return nil, fmt.Errorf("iam auth requires aws credentials")
+	}
+
+	return &IAMTokenGenerator{
+		creds:  aws.NewCredentialsCache(acfg.Credentials),
+		signer: v4.NewSigner(),
+		region: acfg.Region,
+		cfg:    cfg,
+		log:    log,
+		now:    time.Now,
+	}, nil
+}
+
+// Token returns a cached token, generating a new one when close to expiry.
+func (g *IAMTokenGenerator) Token(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	if g.token != "" && now.Before(g.expires.Add(-iamRefreshWindow)) {
+		return g.token, nil
+	}
+
+	creds, err := g.creds.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("retrieve credentials %w", err)
+	}
+
+	token, err := g.presign(ctx, creds, now)
+	if err != nil {
+		return "", err
+	}
+
+	// Tokens signed with session credentials die with the session.
+	expires := now.Add(iamTokenTTL)
+	if creds.CanExpire && creds.Expires.Before(expires) {
+		expires = creds.Expires
+	}
+	g.token = token
+	g.expires = expires
+
+	return token, nil
+}
+
+// CredentialsProvider adapts Token to the go-redis credentials callback.
+// go-redis cannot take an error here so failures are logged and
+// the connection attempt fails authentication.
+func (g *IAMTokenGenerator) CredentialsProvider() func() (string, string) {
+	return func() (string, string) {
+		token, err := g.Token(context.Background())
+		if err != nil {
+			g.log.Error("iam auth token", zap.Error(err))
+			return g.cfg.UserID, ""
+		}
+		return g.cfg.UserID, token
+	}
+}
+
+// presign builds the SigV4 presigned connect request, minus its scheme.
+func (g *IAMTokenGenerator) presign(
+	ctx context.Context,
+	creds aws.Credentials,
+	signingTime time.Time,
+) (string, error) {
+	query := url.Values{
+		"Action":        {"connect"},
+		"User":          {g.cfg.UserID},
+		"X-Amz-Expires": {fmt.Sprintf("%d", int(iamTokenTTL.Seconds()))},
+	}
+	if g.cfg.Serverless {
+		query.Set("ResourceType", "ServerlessCache")
+	}
+
+	reqURL := url.URL{
+		Scheme:   "http",
+		Host:     g.cfg.ReplicationGroupID,
+		Path:     "/",
+		RawQuery: query.Encode(),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("new connect request %w", err)
+	}
+
+	signed, _, err := g.signer.PresignHTTP(
+		ctx,
+		creds,
+		req,
+		emptyPayloadHash,
+		iamService,
+		g.region,
+		signingTime.UTC(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("presign connect request %w", err)
+	}
+
+	return strings.TrimPrefix(signed, "http://"), nil
+}
@@ -0,0 +1,191 @@
+package redis
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"go.uber.org/zap"
+)
+
+// testClock is a settable now.
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+// newTestGenerator returns a generator signing with creds at clock's
+// time, and the count of credential retrievals.
+func newTestGenerator(t *testing.T, creds aws.Credentials, clock *testClock) (*IAMTokenGenerator, *int) {
+	t.Helper()
+	retrieved := new(int)
+	provider := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		*retrieved++
+		return creds, nil
+	})
+	g, err := NewIAMTokenGenerator(aws.Config{
+		Region:      "us-east-1",
+		Credentials: provider,
+	}, IAMConfig{
+		ReplicationGroupID: "my-group",
+		UserID:             "app-user",
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewIAMTokenGenerator: %v", err)
+	}
+	// The credentials cache runs on the real clock, so it is bypassed.
+	g.creds = provider
+	g.now = clock.now
+	return g, retrieved
+}
+
+// staticCreds are long lived test credentials.
+var staticCreds = aws.Credentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+func TestIAMToken(t *testing.T) {
+	clock := &testClock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	g, _ := newTestGenerator(t, staticCreds, clock)
+
+	token, err := g.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if strings.Contains(token, "://") {
+		t.Fatalf("token has a scheme: %s", token)
+	}
+	if !strings.HasPrefix(token, "my-group/?") {
+		t.Fatalf("token host: got %s", token)
+	}
+
+	u, err := url.Parse("http://" + token)
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	query := u.Query()
+	for key, want := range map[string]string{
+		"Action":           "connect",
+		"User":             "app-user",
+		"X-Amz-Expires":    "900",
+		"X-Amz-Date":       "20240102T030405Z",
+		"X-Amz-Algorithm":  "AWS4-HMAC-SHA256",
+		"X-Amz-Credential": "AKIDEXAMPLE/20240102/us-east-1/elasticache/aws4_request",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s: got %q, want %q", key, got, want)
+		}
+	}
+	if query.Get("X-Amz-Signature") == "" {
+		t.Error("missing X-Amz-Signature")
+	}
+	if query.Has("ResourceType") {
+		t.Error("ResourceType set for a replication group")
+	}
+}
+
+func TestIAMTokenServerless(t *testing.T) {
+	clock := &testClock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	g, _ := newTestGenerator(t, staticCreds, clock)
+	g.cfg.Serverless = true
+
+	token, err := g.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	u, err := url.Parse("http://" + token)
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if got := u.Query().Get("ResourceType"); got != "ServerlessCache" {
+		t.Fatalf("ResourceType: got %q, want ServerlessCache", got)
+	}
+}
+
+func TestIAMTokenRefresh(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := &testClock{t: start}
+	g, retrieved := newTestGenerator(t, staticCreds, clock)
+
+	first, err := g.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	// Cached until 5 minutes before the 15 minute expiry.
+	clock.t = start.Add(10*time.Minute - time.Second)
+	cached, err := g.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token cached: %v", err)
+	}
+	if cached != first || *retrieved != 1 {
+		t.Fatalf("token not cached: %d retrievals", *retrieved)
+	}
+
+	clock.t = start.Add(10 * time.Minute)
+	refreshed, err := g.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token refreshed: %v", err)
+	}
+	if refreshed == first || *retrieved != 2 {
+		t.Fatalf("token not refreshed: %d retrievals", *retrieved)
+	}
+}
+
+func TestIAMTokenExpiringCredentials(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := &testClock{t: start}
+	creds := staticCreds
+	creds.SessionToken = "session"
+	creds.CanExpire = true
+	creds.Expires = start.Add(7 * time.Minute)
+	g, retrieved := newTestGenerator(t, creds, clock)
+
+	first, err := g.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	u, err := url.Parse("http://" + first)
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if got := u.Query().Get("X-Amz-Security-Token"); got != "session" {
+		t.Fatalf("X-Amz-Security-Token: got %q", got)
+	}
+
+	// The token dies with the credentials, so it refreshes 5 minutes
+	// before they expire rather than before the token would.
+	clock.t = start.Add(2*time.Minute - time.Second)
+	if _, err := g.Token(context.Background()); err != nil || *retrieved != 1 {
+		t.Fatalf("Token cached: %v, %d retrievals", err, *retrieved)
+	}
+	clock.t = start.Add(2 * time.Minute)
+	refreshed, err := g.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token refreshed: %v", err)
+	}
+	if refreshed == first || *retrieved != 2 {
+		t.Fatalf("token not refreshed: %d retrievals", *retrieved)
+	}
+}
+
+func TestIAMTokenRequiresConfig(t *testing.T) {
+	creds := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return staticCreds, nil
+	})
+	_, err := NewIAMTokenGenerator(aws.Config{Credentials: creds}, IAMConfig{UserID: "u"}, zap.NewNop())
+	if err == nil {
+		t.Fatal("missing replication_group_id: got nil error")
+	}
+	_, err = NewIAMTokenGenerator(aws.Config{}, IAMConfig{ReplicationGroupID: "g", UserID: "u"}, zap.NewNop())
+	if err == nil {
+		t.Fatal("missing credentials: got nil error")
+	}
+}
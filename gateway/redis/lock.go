@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrNotAcquired is returned by Acquire when another holder owns the lock.
+	ErrNotAcquired = errors.New("lock not acquired")
+	// ErrLeaseLost is returned when a lease expired or was taken over.
+	ErrLeaseLost = errors.New("lease lost")
+)
+
+// Lease is a held lock. Fence increases with every acquisition of Key,
+// so writers can reject work from a holder whose lease has lapsed.
+type Lease struct {
+	Key   string
+	Token string
+	Fence int64
+}
+
+// acquireScript sets the lock if free and bumps the fencing counter.
+// KEYS[1] lock, KEYS[2] fence counter. ARGV[1] token, ARGV[2] ttl ms.
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// renewScript extends the lock ttl only while the token still owns it.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lock only while the token still owns it.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// lockKeys returns the lock and fence keys, hash tagged to share a cluster slot.
+func lockKeys(name string) []string {
+	return []string{
+		fmt.Sprintf("lock:{%s}", name),
+		fmt.Sprintf("lock:{%s}:fence", name),
+	}
+}
+
+// Acquire takes the named lock for ttl.
+func (g *gateway) Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	token := uuid.New().String()
+	fence, err := acquireScript.Run(
+		ctx,
+		g.client,
+		lockKeys(name),
+		token,
+		ttl.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return Lease{}, fmt.Errorf("acquire %s %w", name, err)
+	}
+	if fence == 0 {
+		return Lease{}, ErrNotAcquired
+	}
+
+	return Lease{
+		Key:   name,
+		Token: token,
+		Fence: fence,
+	}, nil
+}
+
+// Renew extends a held lease to ttl from now.
+func (g *gateway) Renew(ctx context.Context, lease Lease, ttl time.Duration) error {
+	ok, err := renewScript.Run(
+		ctx,
+		g.client,
+		lockKeys(lease.Key)[:1],
+		lease.Token,
+		ttl.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return fmt.Errorf("renew %s %w", lease.Key, err)
+	}
+	if ok == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+// Release frees a held lease.
+func (g *gateway) Release(ctx context.Context, lease Lease) error {
+	ok, err := releaseScript.Run(
+		ctx,
+		g.client,
+		lockKeys(lease.Key)[:1],
+		lease.Token,
+	).Int64()
+	if err != nil {
+		return fmt.Errorf("release %s %w", lease.Key, err)
+	}
+	if ok == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
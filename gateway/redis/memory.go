@@ -0,0 +1,262 @@
+package redis
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// memory implements Gateway in process for local dev and tests.
+// Entries expire like redis keys and the least recently used entry
+// is evicted once maxEntries is reached.
+type memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	now        func() time.Time
+	ll         *list.List
+	items      map[string]*list.Element
+	locks      map[string]heldLock
+	fences     map[string]int64
+	buckets    map[string]*bucket
+	hub        *hub
+	queues     map[string]*memQueue
+	seq        int64
+}
+
+// bucketSweepSize is the bucket count that triggers dropping refilled buckets.
+const bucketSweepSize = 10000
+
+// heldLock is the current owner of a named lock.
+type heldLock struct {
+	token   string
+	expires time.Time
+}
+
+// entry is a single cached key value pair.
+type entry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+// NewMemory is the in memory Gateway constructor.
+// A maxEntries of zero or less leaves the cache unbounded.
+func NewMemory(maxEntries int) Gateway {
+	return newMemory(maxEntries, newHub(defaultPubSubBuffer, zap.NewNop()))
+}
+
+// newMemory builds the in memory Gateway publishing through h.
+func newMemory(maxEntries int, h *hub) *memory {
+	return &memory{
+		maxEntries: maxEntries,
+		now:        time.Now,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		locks:      make(map[string]heldLock),
+		fences:     make(map[string]int64),
+		buckets:    make(map[string]*bucket),
+		hub:        h,
+		queues:     make(map[string]*memQueue),
+	}
+}
+
+// Set stores a key value pair in a cache.
+// An exp of zero never expires, redis.KeepTTL retains the current expiry.
+func (m *memory) Set(ctx context.Context, key, value string, exp time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expires time.Time
+	if exp > 0 {
+		expires = m.now().Add(exp)
+	}
+
+	if el, ok := m.items[key]; ok {
+		e := el.Value.(*entry)
+		if exp == redis.KeepTTL && !m.expired(e) {
+			expires = e.expires
+		}
+		e.value = value
+		e.expires = expires
+		m.ll.MoveToFront(el)
+		return nil
+	}
+
+	m.items[key] = m.ll.PushFront(&entry{
+		key:     key,
+		value:   value,
+		expires: expires,
+	})
+	if m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		m.removeElement(m.ll.Back())
+	}
+
+	return nil
+}
+
+// Get collects value by key from cache.
+// Returns Nil when the key is missing or expired.
+func (m *memory) Get(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return "", Nil
+	}
+
+	e := el.Value.(*entry)
+	if m.expired(e) {
+		m.removeElement(el)
+		return "", Nil
+	}
+	m.ll.MoveToFront(el)
+
+	return e.value, nil
+}
+
+// expired reports whether the entry's ttl has elapsed.
+func (m *memory) expired(e *entry) bool {
+	return !e.expires.IsZero() && !m.now().Before(e.expires)
+}
+
+// removeElement drops an element from both the list and index.
+func (m *memory) removeElement(el *list.Element) {
+	m.ll.Remove(el)
+	delete(m.items, el.Value.(*entry).key)
+}
+
+// Acquire takes the named lock for ttl.
+func (m *memory) Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	if err := ctx.Err(); err != nil {
+		return Lease{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if held, ok := m.locks[name]; ok && now.Before(held.expires) {
+		return Lease{}, ErrNotAcquired
+	}
+
+	token := uuid.New().String()
+	m.locks[name] = heldLock{
+		token:   token,
+		expires: now.Add(ttl),
+	}
+	m.fences[name]++
+
+	return Lease{
+		Key:   name,
+		Token: token,
+		Fence: m.fences[name],
+	}, nil
+}
+
+// Renew extends a held lease to ttl from now.
+func (m *memory) Renew(ctx context.Context, lease Lease, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if !m.owns(lease, now) {
+		return ErrLeaseLost
+	}
+	m.locks[lease.Key] = heldLock{
+		token:   lease.Token,
+		expires: now.Add(ttl),
+	}
+
+	return nil
+}
+
+// Release frees a held lease.
+func (m *memory) Release(ctx context.Context, lease Lease) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.owns(lease, m.now()) {
+		return ErrLeaseLost
+	}
+	delete(m.locks, lease.Key)
+
+	return nil
+}
+
+// owns reports whether lease is the unexpired holder of its lock.
+func (m *memory) owns(lease Lease, now time.Time) bool {
+	held, ok := m.locks[lease.Key]
+	return ok && held.token == lease.Token && now.Before(held.expires)
+}
+
+// Allow takes a token from the bucket named key.
+func (m *memory) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	if err := limit.valid(); err != nil {
+		return Result{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	b, ok := m.buckets[key]
+	if !ok {
+		// Refilled buckets behave like new ones, drop them to bound memory.
+		if len(m.buckets) >= bucketSweepSize {
+			for k, old := range m.buckets {
+				if !now.Before(old.full) {
+					delete(m.buckets, k)
+				}
+			}
+		}
+		b = &bucket{
+			tokens: float64(limit.Burst),
+			ts:     now,
+		}
+		m.buckets[key] = b
+	}
+
+	return b.take(now, limit), nil
+}
+
+// Publish sends message to every subscriber of channel in this process.
+func (m *memory) Publish(ctx context.Context, channel, message string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.hub.deliver(channel, message)
+	return nil
+}
+
+// Subscribe receives messages published to channel until closed.
+func (m *memory) Subscribe(ctx context.Context, channel string) (*Subscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.hub.add(channel)
+}
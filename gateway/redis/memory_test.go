@@ -0,0 +1,58 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"fx-sample-app/gateway/redis"
+	"fx-sample-app/gateway/redis/redistest"
+)
+
+func TestMemory(t *testing.T) {
+	redistest.Run(t, func(t *testing.T) redis.Gateway {
+		return redis.NewMemory(0)
+	})
+}
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	g := redis.NewMemory(2)
+	ctx := context.Background()
+	for _, k := range []string{"a", "b"} {
+		if err := g.Set(ctx, k, k, 0); err != nil {
+			t.Fatalf("Set %s: %v", k, err)
+		}
+	}
+	// Reading a makes b the least recently used.
+	if _, err := g.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get a: %v", err)
+	}
+	if err := g.Set(ctx, "c", "c", 0); err != nil {
+		t.Fatalf("Set c: %v", err)
+	}
+
+	if _, err := g.Get(ctx, "b"); !errors.Is(err, redis.Nil) {
+		t.Fatalf("Get evicted b: got %v, want %v", err, redis.Nil)
+	}
+	for _, k := range []string{"a", "c"} {
+		got, err := g.Get(ctx, k)
+		if err != nil || got != k {
+			t.Fatalf("Get %s: got %q, %v", k, got, err)
+		}
+	}
+}
+
+func TestMemoryOverwriteDoesNotEvict(t *testing.T) {
+	g := redis.NewMemory(2)
+	ctx := context.Background()
+	for _, k := range []string{"a", "b", "a", "b"} {
+		if err := g.Set(ctx, k, k, 0); err != nil {
+			t.Fatalf("Set %s: %v", k, err)
+		}
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, err := g.Get(ctx, k); err != nil {
+			t.Fatalf("Get %s: %v", k, err)
+		}
+	}
+}
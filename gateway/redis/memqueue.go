@@ -0,0 +1,276 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// memQueue is the in memory state of one job queue.
+type memQueue struct {
+	ready   []Job
+	pending map[string]*pendingJob
+	delayed []delayedJob
+	dead    []Job
+	// notify is closed and replaced whenever jobs become ready.
+	notify chan struct{}
+}
+
+// pendingJob is a delivered job awaiting acknowledgement.
+type pendingJob struct {
+	seq       int64
+	job       Job
+	consumer  string
+	delivered time.Time
+}
+
+// delayedJob is a retry waiting for its due time.
+type delayedJob struct {
+	job Job
+	due time.Time
+}
+
+// queue returns the named queue, creating it on first use.
+// Callers must hold m.mu.
+func (m *memory) queue(name string) *memQueue {
+	q, ok := m.queues[name]
+	if !ok {
+		q = &memQueue{
+			pending: make(map[string]*pendingJob),
+			notify:  make(chan struct{}),
+		}
+		m.queues[name] = q
+	}
+	return q
+}
+
+// nextID returns a stream style entry id. Callers must hold m.mu.
+func (m *memory) nextID() string {
+	m.seq++
+	return fmt.Sprintf("%d-0", m.seq)
+}
+
+// idSeq parses the sequence back out of an entry id.
+func idSeq(id string) int64 {
+	var seq int64
+	fmt.Sscanf(id, "%d-", &seq)
+	return seq
+}
+
+// push appends a job to the ready list and wakes blocked consumers.
+// Callers must hold m.mu.
+func (m *memory) push(q *memQueue, job Job) Job {
+	job.ID = m.nextID()
+	q.ready = append(q.ready, job)
+	close(q.notify)
+	q.notify = make(chan struct{})
+	return job
+}
+
+// Enqueue adds a job for immediate delivery and returns its JobID.
+func (m *memory) Enqueue(ctx context.Context, queue string, job Job) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job = m.push(m.queue(queue), newJob(job))
+	return job.JobID, nil
+}
+
+// Dequeue delivers up to count new jobs to consumer, waiting up to block.
+func (m *memory) Dequeue(
+	ctx context.Context,
+	queue, consumer string,
+	count int,
+	block time.Duration,
+) ([]Job, error) {
+	deadline := m.now().Add(block)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+		q := m.queue(queue)
+		now := m.now()
+
+		// Promote due retries, keeping the rest in due order.
+		sort.SliceStable(q.delayed, func(i, j int) bool {
+			return q.delayed[i].due.Before(q.delayed[j].due)
+		})
+		for len(q.delayed) > 0 && !now.Before(q.delayed[0].due) {
+			m.push(q, q.delayed[0].job)
+			q.delayed = q.delayed[1:]
+		}
+
+		n := min(count, len(q.ready))
+		jobs := make([]Job, 0, n)
+		for _, job := range q.ready[:n] {
+			q.pending[job.ID] = &pendingJob{
+				seq:       idSeq(job.ID),
+				job:       job,
+				consumer:  consumer,
+				delivered: now,
+			}
+			jobs = append(jobs, job)
+		}
+		q.ready = q.ready[n:]
+
+		wait := deadline.Sub(now)
+		if len(q.delayed) > 0 {
+			wait = min(wait, q.delayed[0].due.Sub(now))
+		}
+		notify := q.notify
+		m.mu.Unlock()
+
+		if len(jobs) > 0 || !now.Before(deadline) {
+			return jobs, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-notify:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Reclaim takes over jobs left unacknowledged for minIdle by other consumers.
+func (m *memory) Reclaim(
+	ctx context.Context,
+	queue, consumer string,
+	minIdle time.Duration,
+	count int,
+) ([]Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q := m.queue(queue)
+	now := m.now()
+
+	idle := make([]*pendingJob, 0, len(q.pending))
+	for _, p := range q.pending {
+		if now.Sub(p.delivered) >= minIdle {
+			idle = append(idle, p)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool {
+		return idle[i].seq < idle[j].seq
+	})
+
+	jobs := make([]Job, 0, min(count, len(idle)))
+	for _, p := range idle[:min(count, len(idle))] {
+		p.consumer = consumer
+		p.delivered = now
+		jobs = append(jobs, p.job)
+	}
+
+	return jobs, nil
+}
+
+// Ack completes a delivered job.
+func (m *memory) Ack(ctx context.Context, queue string, job Job) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.queue(queue).pending, job.ID)
+	return nil
+}
+
+// Retry completes a delivery and schedules the job again after delay.
+func (m *memory) Retry(ctx context.Context, queue string, job Job, delay time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q := m.queue(queue)
+	delete(q.pending, job.ID)
+	q.delayed = append(q.delayed, delayedJob{
+		job: job,
+		due: m.now().Add(delay),
+	})
+
+	return nil
+}
+
+// DeadLetter completes a delivery and parks the job for inspection.
+func (m *memory) DeadLetter(ctx context.Context, queue string, job Job) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q := m.queue(queue)
+	delete(q.pending, job.ID)
+	job.ID = m.nextID()
+	q.dead = append(q.dead, job)
+	if len(q.dead) > deadMaxLen {
+		q.dead = q.dead[len(q.dead)-deadMaxLen:]
+	}
+
+	return nil
+}
+
+// DeadLetters lists up to count parked jobs, oldest first.
+func (m *memory) DeadLetters(ctx context.Context, queue string, count int) ([]Job, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dead := m.queue(queue).dead
+	jobs := make([]Job, min(count, len(dead)))
+	copy(jobs, dead)
+
+	return jobs, nil
+}
+
+// Replay moves a parked job back to the queue with a fresh attempt count.
+func (m *memory) Replay(ctx context.Context, queue, id string) (Job, error) {
+	if err := ctx.Err(); err != nil {
+		return Job{}, err
+	}
+
+	if !validEntryID(id) {
+		return Job{}, fmt.Errorf("%w %q", ErrInvalidJobID, id)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q := m.queue(queue)
+	for i, job := range q.dead {
+		if job.ID != id {
+			continue
+		}
+		q.dead = append(q.dead[:i:i], q.dead[i+1:]...)
+		job.Attempt = 0
+		job.LastError = ""
+		return m.push(q, job), nil
+	}
+
+	return Job{}, ErrJobNotFound
+}
@@ -0,0 +1,226 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultPubSubBuffer is the per subscription buffer when unset.
+	defaultPubSubBuffer = 100
+	// pubsubPing checks an idle subscriber connection is still alive.
+	pubsubPing = 30 * time.Second
+	// pubsubMaxBackoff caps the wait between reconnect attempts.
+	pubsubMaxBackoff = 5 * time.Second
+)
+
+// Message is a payload published on a channel.
+type Message struct {
+	Channel string
+	Payload string
+}
+
+// Subscription delivers messages for one channel until closed.
+// Messages are dropped when C is not drained fast enough.
+type Subscription struct {
+	C <-chan Message
+
+	ch      chan Message
+	channel string
+	hub     *hub
+	once    sync.Once
+}
+
+// Close stops delivery and closes C.
+func (s *Subscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		err = s.hub.remove(s)
+	})
+	return err
+}
+
+// hub fans messages out to local subscriptions with bounded buffers.
+// first and last, when set, are called as a channel gains its first
+// or loses its last local subscription.
+type hub struct {
+	mu     sync.Mutex
+	buffer int
+	log    *zap.Logger
+	subs   map[string]map[*Subscription]struct{}
+	closed bool
+	first  func(channel string) error
+	last   func(channel string) error
+}
+
+// newHub is the hub constructor.
+func newHub(buffer int, log *zap.Logger) *hub {
+	if buffer <= 0 {
+		buffer = defaultPubSubBuffer
+	}
+	return &hub{
+		buffer: buffer,
+		log:    log,
+		subs:   make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// add registers a new subscription on channel.
+func (h *hub) add(channel string) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, fmt.Errorf("subscribe %s: pubsub closed", channel)
+	}
+
+	subs, ok := h.subs[channel]
+	if !ok {
+		if h.first != nil {
+			err := h.first(channel)
+			if err != nil {
+				return nil, fmt.Errorf("subscribe %s %w", channel, err)
+			}
+		}
+		subs = make(map[*Subscription]struct{})
+		h.subs[channel] = subs
+	}
+
+	ch := make(chan Message, h.buffer)
+	sub := &Subscription{
+		C:       ch,
+		ch:      ch,
+		channel: channel,
+		hub:     h,
+	}
+	subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// remove unregisters a subscription and closes its channel.
+func (h *hub) remove(sub *Subscription) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs, ok := h.subs[sub.channel]
+	if !ok {
+		return nil
+	}
+	if _, ok := subs[sub]; !ok {
+		return nil
+	}
+	delete(subs, sub)
+	close(sub.ch)
+
+	if len(subs) > 0 {
+		return nil
+	}
+	delete(h.subs, sub.channel)
+	if h.last != nil {
+		err := h.last(sub.channel)
+		if err != nil {
+			return fmt.Errorf("unsubscribe %s %w", sub.channel, err)
+		}
+	}
+
+	return nil
+}
+
+// deliver hands a message to every subscription on channel without blocking.
+func (h *hub) deliver(channel, payload string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	msg := Message{
+		Channel: channel,
+		Payload: payload,
+	}
+	for sub := range h.subs[channel] {
+		select {
+		case sub.ch <- msg:
+		default:
+			h.log.Warn("pubsub buffer full, dropping message",
+				zap.String("channel", channel),
+			)
+		}
+	}
+}
+
+// close ends every subscription and rejects new ones.
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for channel, subs := range h.subs {
+		for sub := range subs {
+			close(sub.ch)
+		}
+		delete(h.subs, channel)
+	}
+}
+
+// Publish sends message to every subscriber of channel on any instance.
+func (g *gateway) Publish(ctx context.Context, channel, message string) error {
+	err := g.client.Publish(ctx, channel, message).Err()
+	if err != nil {
+		return fmt.Errorf("publish %s %w", channel, err)
+	}
+	return nil
+}
+
+// Subscribe receives messages published to channel until the
+// subscription is closed or the app stops.
+func (g *gateway) Subscribe(ctx context.Context, channel string) (*Subscription, error) {
+	return g.hub.add(channel)
+}
+
+// receive reads the shared subscriber connection until ctx is canceled.
+// go-redis reconnects and resubscribes known channels on the next read
+// after a failure, this loop backs off between attempts and pings idle
+// connections so half open ones are noticed.
+func (g *gateway) receive(ctx context.Context) {
+	backoff := 100 * time.Millisecond
+	for {
+		msg, err := g.pubsub.ReceiveTimeout(ctx, pubsubPing)
+		if ctx.Err() != nil {
+			return
+		}
+
+		var netErr net.Error
+		switch {
+		case err == nil:
+			backoff = 100 * time.Millisecond
+		case errors.As(err, &netErr) && netErr.Timeout():
+			// Idle, a failed ping drops the connection for reconnect.
+			if err := g.pubsub.Ping(ctx); err != nil {
+				g.log.Warn("pubsub ping", zap.Error(err))
+			}
+			continue
+		default:
+			g.log.Warn("pubsub receive, reconnecting",
+				zap.Duration("backoff", backoff),
+				zap.Error(err),
+			)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, pubsubMaxBackoff)
+			continue
+		}
+
+		if m, ok := msg.(*redis.Message); ok {
+			g.hub.deliver(m.Channel, m.Payload)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// jobGroup is the consumer group every worker joins.
+	jobGroup = "workers"
+	// jobField is the stream entry field holding the encoded job.
+	jobField = "job"
+	// promoteBatch bounds delayed jobs moved per dequeue.
+	promoteBatch = 100
+	// deadMaxLen approximately bounds each dead letter stream.
+	deadMaxLen = 10000
+)
+
+// ErrJobNotFound is returned when a job id does not exist.
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrInvalidJobID is returned for ids that are not stream entry ids.
+var ErrInvalidJobID = errors.New("invalid job id")
+
+// Job is a unit of deferred work. Deliveries are at least once,
+// handlers should be idempotent on JobID.
+type Job struct {
+	// ID is the stream entry id of this delivery.
+	ID string `json:"-"`
+	// JobID is assigned on enqueue and kept across retries.
+	JobID      string          `json:"job_id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Attempt    int             `json:"attempt"`
+	LastError  string          `json:"last_error,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+}
+
+// JobQueue defines durable queue interaction methods.
+// Each queue has ready, delayed and dead letter stores.
+type JobQueue interface {
+	// Enqueue adds a job for immediate delivery and returns its JobID.
+	Enqueue(ctx context.Context, queue string, job Job) (string, error)
+	// Dequeue delivers up to count new jobs to consumer, waiting up to block.
+	Dequeue(ctx context.Context, queue, consumer string, count int, block time.Duration) ([]Job, error)
+	// Reclaim takes over jobs left unacknowledged for minIdle by other consumers.
+	Reclaim(ctx context.Context, queue, consumer string, minIdle time.Duration, count int) ([]Job, error)
+	// Ack completes a delivered job.
+	Ack(ctx context.Context, queue string, job Job) error
+	// Retry completes a delivery and schedules the job again after delay.
+	Retry(ctx context.Context, queue string, job Job, delay time.Duration) error
+	// DeadLetter completes a delivery and parks the job for inspection.
+	DeadLetter(ctx context.Context, queue string, job Job) error
+	// DeadLetters lists up to count parked jobs, oldest first.
+	DeadLetters(ctx context.Context, queue string, count int) ([]Job, error)
+	// Replay moves a parked job back to the queue with a fresh attempt count.
+	Replay(ctx context.Context, queue, id string) (Job, error)
+}
+
+// queueKeys names a queue's keys, hash tagged to share a cluster slot.
+type queueKeys struct {
+	ready, delayed, dead string
+}
+
+// keysFor returns the keys backing queue.
+func keysFor(queue string) queueKeys {
+	return queueKeys{
+		ready:   fmt.Sprintf("jobs:{%s}", queue),
+		delayed: fmt.Sprintf("jobs:{%s}:delayed", queue),
+		dead:    fmt.Sprintf("jobs:{%s}:dead", queue),
+	}
+}
+
+// promoteScript moves due delayed jobs onto the ready stream.
+// KEYS[1] delayed set, KEYS[2] ready stream. ARGV[1] now ms, ARGV[2] limit.
+var promoteScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, job in ipairs(due) do
+	redis.call("XADD", KEYS[2], "*", "job", job)
+	redis.call("ZREM", KEYS[1], job)
+end
+return #due
+`)
+
+// replayScript moves a dead letter to the ready stream if it is still
+// parked as read. KEYS[1] dead stream, KEYS[2] ready stream. ARGV[1]
+// entry id, ARGV[2] parked job, ARGV[3] job to requeue.
+var replayScript = redis.NewScript(`
+local entries = redis.call("XRANGE", KEYS[1], ARGV[1], ARGV[1])
+if #entries == 0 then
+	return false
+end
+local fields = entries[1][2]
+local parked
+for i = 1, #fields, 2 do
+	if fields[i] == "job" then
+		parked = fields[i + 1]
+	end
+end
+if parked ~= ARGV[2] then
+	return false
+end
+redis.call("XDEL", KEYS[1], ARGV[1])
+return redis.call("XADD", KEYS[2], "*", "job", ARGV[3])
+`)
+
+// validEntryID reports whether id is a full stream entry id such as
+// 1700000000000-0.
+func validEntryID(id string) bool {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	_, msErr := strconv.ParseUint(ms, 10, 64)
+	_, seqErr := strconv.ParseUint(seq, 10, 64)
+	return msErr == nil && seqErr == nil
+}
+
+// ensureGroup creates the consumer group once per stream.
+func (g *gateway) ensureGroup(ctx context.Context, stream string) error {
+	if _, ok := g.groups.Load(stream); ok {
+		return nil
+	}
+
+	err := g.client.XGroupCreateMkStream(ctx, stream, jobGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("create group %s %w", stream, err)
+	}
+	g.groups.Store(stream, struct{}{})
+
+	return nil
+}
+
+// Enqueue adds a job for immediate delivery and returns its JobID.
+func (g *gateway) Enqueue(ctx context.Context, queue string, job Job) (string, error) {
+	job = newJob(job)
+	encoded, err := json.Marshal(job)
+	if err != nil {
+		return "", fmt.Errorf("marshal job %w", err)
+	}
+
+	err = g.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: keysFor(queue).ready,
+		Values: []interface{}{jobField, encoded},
+	}).Err()
+	if err != nil {
+		return "", fmt.Errorf("enqueue %s %w", queue, err)
+	}
+
+	return job.JobID, nil
+}
+
+// Dequeue delivers up to count new jobs to consumer, waiting up to block.
+func (g *gateway) Dequeue(
+	ctx context.Context,
+	queue, consumer string,
+	count int,
+	block time.Duration,
+) ([]Job, error) {
+	keys := keysFor(queue)
+	err := g.ensureGroup(ctx, keys.ready)
+	if err != nil {
+		return nil, err
+	}
+
+	err = promoteScript.Run(
+		ctx,
+		g.client,
+		[]string{keys.delayed, keys.ready},
+		time.Now().UnixMilli(),
+		promoteBatch,
+	).Err()
+	if err != nil {
+		return nil, fmt.Errorf("promote delayed %s %w", queue, err)
+	}
+
+	streams, err := g.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    jobGroup,
+		Consumer: consumer,
+		Streams:  []string{keys.ready, ">"},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dequeue %s %w", queue, err)
+	}
+
+	var msgs []redis.XMessage
+	for _, stream := range streams {
+		msgs = append(msgs, stream.Messages...)
+	}
+	return decodeJobs(msgs)
+}
+
+// Reclaim takes over jobs left unacknowledged for minIdle by other consumers.
+func (g *gateway) Reclaim(
+	ctx context.Context,
+	queue, consumer string,
+	minIdle time.Duration,
+	count int,
+) ([]Job, error) {
+	keys := keysFor(queue)
+	err := g.ensureGroup(ctx, keys.ready)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, _, err := g.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   keys.ready,
+		Group:    jobGroup,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    "0-0",
+		Count:    int64(count),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("reclaim %s %w", queue, err)
+	}
+
+	return decodeJobs(msgs)
+}
+
+// Ack completes a delivered job.
+func (g *gateway) Ack(ctx context.Context, queue string, job Job) error {
+	_, err := g.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		ack(ctx, pipe, keysFor(queue).ready, job.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ack %s %w", job.ID, err)
+	}
+	return nil
+}
+
+// Retry completes a delivery and schedules the job again after delay.
+func (g *gateway) Retry(ctx context.Context, queue string, job Job, delay time.Duration) error {
+	encoded, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("marshal job %w", err)
+	}
+
+	keys := keysFor(queue)
+	_, err = g.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, keys.delayed, redis.Z{
+			Score:  float64(time.Now().Add(delay).UnixMilli()),
+			Member: encoded,
+		})
+		ack(ctx, pipe, keys.ready, job.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("retry %s %w", job.ID, err)
+	}
+	return nil
+}
+
+// DeadLetter completes a delivery and parks the job for inspection.
+func (g *gateway) DeadLetter(ctx context.Context, queue string, job Job) error {
+	encoded, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("marshal job %w", err)
+	}
+
+	keys := keysFor(queue)
+	_, err = g.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: keys.dead,
+			MaxLen: deadMaxLen,
+			Approx: true,
+			Values: []interface{}{jobField, encoded},
+		})
+		ack(ctx, pipe, keys.ready, job.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("dead letter %s %w", job.ID, err)
+	}
+	return nil
+}
+
+// DeadLetters lists up to count parked jobs, oldest first.
+func (g *gateway) DeadLetters(ctx context.Context, queue string, count int) ([]Job, error) {
+	msgs, err := g.client.XRangeN(ctx, keysFor(queue).dead, "-", "+", int64(count)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("dead letters %s %w", queue, err)
+	}
+	return decodeJobs(msgs)
+}
+
+// Replay moves a parked job back to the queue with a fresh attempt
+// count. Of concurrent replays of one job only the first requeues it,
+// the others get ErrJobNotFound.
+func (g *gateway) Replay(ctx context.Context, queue, id string) (Job, error) {
+	if !validEntryID(id) {
+		return Job{}, fmt.Errorf("%w %q", ErrInvalidJobID, id)
+	}
+
+	keys := keysFor(queue)
+	msgs, err := g.client.XRange(ctx, keys.dead, id, id).Result()
+	if err != nil {
+		return Job{}, fmt.Errorf("find dead letter %s %w", id, err)
+	}
+	jobs, err := decodeJobs(msgs)
+	if err != nil {
+		return Job{}, err
+	}
+	if len(jobs) == 0 {
+		return Job{}, ErrJobNotFound
+	}
+	parked, _ := msgs[0].Values[jobField].(string)
+
+	job := jobs[0]
+	job.Attempt = 0
+	job.LastError = ""
+	encoded, err := json.Marshal(job)
+	if err != nil {
+		return Job{}, fmt.Errorf("marshal job %w", err)
+	}
+
+	job.ID, err = replayScript.Run(
+		ctx,
+		g.client,
+		[]string{keys.dead, keys.ready},
+		id,
+		parked,
+		encoded,
+	).Text()
+	if errors.Is(err, redis.Nil) {
+		return Job{}, ErrJobNotFound
+	}
+	if err != nil {
+		return Job{}, fmt.Errorf("replay %s %w", id, err)
+	}
+
+	return job, nil
+}
+
+// ack acknowledges and deletes a delivered entry so the stream stays small.
+func ack(ctx context.Context, pipe redis.Pipeliner, stream, id string) {
+	pipe.XAck(ctx, stream, jobGroup, id)
+	pipe.XDel(ctx, stream, id)
+}
+
+// newJob fills the fields set on enqueue.
+func newJob(job Job) Job {
+	if job.JobID == "" {
+		job.JobID = uuid.New().String()
+	}
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now().UTC()
+	}
+	job.ID = ""
+	return job
+}
+
+// decodeJobs parses stream entries, skipping entries deleted while pending.
+// Undecodable entries come back without a Type so workers dead letter them.
+func decodeJobs(msgs []redis.XMessage) ([]Job, error) {
+	jobs := make([]Job, 0, len(msgs))
+	for _, msg := range msgs {
+		raw, ok := msg.Values[jobField].(string)
+		if !ok {
+			continue
+		}
+
+		var job Job
+		err := json.Unmarshal([]byte(raw), &job)
+		if err != nil {
+			// Keep the raw entry as a JSON string for inspection.
+			quoted, _ := json.Marshal(raw)
+			job = Job{
+				Payload:   quoted,
+				LastError: fmt.Sprintf("unmarshal job %v", err),
+			}
+		}
+		job.ID = msg.ID
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
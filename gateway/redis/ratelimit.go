@@ -0,0 +1,124 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// Limit defines a token bucket refilled with Events tokens every Period,
+// holding at most Burst tokens.
+type Limit struct {
+	Events int           `yaml:"events"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+}
+
+// Result is the outcome of a rate limited call.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until a token is available, zero when allowed.
+	RetryAfter time.Duration
+}
+
+// valid reports whether the limit can refill.
+func (l Limit) valid() error {
+	if l.Events <= 0 || l.Period <= 0 || l.Burst <= 0 {
+		return fmt.Errorf("invalid limit %+v", l)
+	}
+	return nil
+}
+
+// perMilli returns the refill rate in tokens per millisecond.
+func (l Limit) perMilli() float64 {
+	return float64(l.Events) / float64(l.Period.Milliseconds())
+}
+
+// allowScript takes one token from the bucket using the server clock.
+// KEYS[1] bucket. ARGV[1] tokens per ms, ARGV[2] burst.
+// Returns allowed, remaining tokens and retry after in ms.
+var allowScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+return {allowed, math.floor(tokens), retry}
+`)
+
+// Allow takes a token from the bucket named key.
+func (g *gateway) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := limit.valid(); err != nil {
+		return Result{}, err
+	}
+
+	res, err := allowScript.Run(
+		ctx,
+		g.client,
+		[]string{"ratelimit:" + key},
+		limit.perMilli(),
+		limit.Burst,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("allow %s %w", key, err)
+	}
+	if len(res) != 3 {
+		return Result{}, fmt.Errorf("allow %s unexpected reply %v", key, res)
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// bucket is the in memory token bucket state.
+type bucket struct {
+	tokens float64
+	ts     time.Time
+	full   time.Time
+}
+
+// take refills the bucket to now and takes a token if available.
+func (b *bucket) take(now time.Time, limit Limit) Result {
+	rate := limit.perMilli()
+	elapsed := math.Max(0, float64(now.Sub(b.ts).Milliseconds()))
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*rate)
+	b.ts = now
+	b.full = now.Add(time.Duration(math.Ceil(float64(limit.Burst)/rate)) * time.Millisecond)
+
+	if b.tokens < 1 {
+		retry := math.Ceil((1 - b.tokens) / rate)
+		return Result{
+			Remaining:  int(b.tokens),
+			RetryAfter: time.Duration(retry) * time.Millisecond,
+		}
+	}
+
+	b.tokens--
+	return Result{
+		Allowed:   true,
+		Remaining: int(b.tokens),
+	}
+}
@@ -2,45 +2,184 @@ package redis
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	redis "github.com/redis/go-redis/v9"
 	"go.uber.org/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// Nil is returned by Get when the key does not exist.
+// Both backends return this value so callers can compare against it.
+const Nil = redis.Nil
+
+// Backend names selectable with cache.backend.
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
 )
 
 // Gateway defines redis interaction methods.
 type Gateway interface {
 	Set(ctx context.Context, key, value string, exp time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
+	Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error)
+	Renew(ctx context.Context, lease Lease, ttl time.Duration) error
+	Release(ctx context.Context, lease Lease) error
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	Publish(ctx context.Context, channel, message string) error
+	Subscribe(ctx context.Context, channel string) (*Subscription, error)
+	JobQueue
 }
 
 type gateway struct {
-	client *redis.Client
+	client redis.UniversalClient
+	pubsub *redis.PubSub
+	hub    *hub
+	log    *zap.Logger
+	// groups tracks streams whose consumer group exists.
+	groups sync.Map
+}
+
+// Params defines constructor requirements.
+type Params struct {
+	fx.In
+
+	Cfg  config.Provider
+	Acfg aws.Config
+	Lc   fx.Lifecycle
+	Log  *zap.Logger
 }
 
 // New is the redis gateway constructor.
-func New(cfg config.Provider, acfg aws.Config) Gateway {
-	credCache := aws.NewCredentialsCache(acfg.Credentials)
-	opts := &redis.Options{
-		Addr:     cfg.Get("redis.address").String(),
-		Password: "", // no password set.
-		DB:       0,  // uses default db.
-	}
-	// If dev field is not empty, we're deployed, add provider.
-	if cfg.Get("dev").String() != "" {
-		opts.CredentialsProvider = func() (string, string) {
-			ctx := context.Background()
-			creds, err := credCache.Retrieve(ctx)
+// The cache.backend config selects between redis and an in memory cache.
+func New(p Params) (Gateway, error) {
+	var buffer int
+	err := p.Cfg.Get("cache.pubsub_buffer").Populate(&buffer)
+	if err != nil {
+		return nil, fmt.Errorf("cache pubsub_buffer %w", err)
+	}
+
+	switch backend := p.Cfg.Get("cache.backend").String(); backend {
+	case BackendMemory:
+		var maxEntries int
+		err := p.Cfg.Get("cache.max_entries").Populate(&maxEntries)
+		if err != nil {
+			return nil, fmt.Errorf("cache max_entries %w", err)
+		}
+		m := newMemory(maxEntries, newHub(buffer, p.Log))
+		p.Lc.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				m.hub.close()
+				return nil
+			},
+		})
+		return m, nil
+	case BackendRedis, "":
+		return newRedis(p, newHub(buffer, p.Log))
+	default:
+		return nil, fmt.Errorf("unsupported cache backend %q", backend)
+	}
+}
+
+// newRedis builds the Gateway backed by a redis server.
+func newRedis(p Params, h *hub) (Gateway, error) {
+	var rcfg Config
+	err := p.Cfg.Get("redis").Populate(&rcfg)
+	if err != nil {
+		return nil, fmt.Errorf("redis config %w", err)
+	}
+
+	var creds func() (string, string)
+	if rcfg.IAM.Enabled {
+		tokens, err := NewIAMTokenGenerator(p.Acfg, rcfg.IAM, p.Log)
+		if err != nil {
+			return nil, err
+		}
+		creds = tokens.CredentialsProvider()
+	}
+
+	client, err := NewClient(rcfg, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &gateway{
+		client: client,
+		pubsub: client.Subscribe(context.Background()),
+		hub:    h,
+		log:    p.Log,
+	}
+	// Local subscriptions share one subscriber connection.
+	h.first = func(channel string) error {
+		return g.pubsub.Subscribe(context.Background(), channel)
+	}
+	h.last = func(channel string) error {
+		return g.pubsub.Unsubscribe(context.Background(), channel)
+	}
+
+	receiveCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.Lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			err := client.Ping(ctx).Err()
 			if err != nil {
-				return "", ""
+				return fmt.Errorf("redis ping %w", err)
 			}
-			return "", creds.SessionToken
-		}
+			p.Log.Info("connected to redis",
+				zap.String("mode", rcfg.Mode),
+				zap.Strings("addresses", rcfg.Addresses),
+			)
+
+			go func() {
+				defer close(done)
+				g.receive(receiveCtx)
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			g.pubsub.Close()
+			<-done
+			g.hub.close()
+			return client.Close()
+		},
+	})
+
+	return g, nil
+}
+
+// NewClient builds a client for the configured topology.
+// creds is optional and replaces the static username and password.
+func NewClient(rcfg Config, creds func() (string, string)) (redis.UniversalClient, error) {
+	opts, err := rcfg.options()
+	if err != nil {
+		return nil, err
 	}
 
-	return &gateway{
-		client: redis.NewClient(opts),
+	switch rcfg.Mode {
+	case ModeSentinel:
+		if creds != nil {
+			return nil, fmt.Errorf("credentials provider unsupported in sentinel mode")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case ModeCluster:
+		clusterOpts := opts.Cluster()
+		if creds != nil {
+			clusterOpts.NewClient = func(opt *redis.Options) *redis.Client {
+				opt.CredentialsProvider = creds
+				return redis.NewClient(opt)
+			}
+		}
+		return redis.NewClusterClient(clusterOpts), nil
+	default:
+		simpleOpts := opts.Simple()
+		simpleOpts.CredentialsProvider = creds
+		return redis.NewClient(simpleOpts), nil
 	}
 }
 
@@ -0,0 +1,53 @@
+package redis_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"go.uber.org/config"
+	"go.uber.org/fx/fxtest"
+	"go.uber.org/zap/zaptest"
+
+	"fx-sample-app/gateway/redis"
+	"fx-sample-app/gateway/redis/redistest"
+)
+
+// TestRedis runs the conformance suite against the server at
+// REDIS_ADDRESS, skipped when unset.
+func TestRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDRESS")
+	if addr == "" {
+		t.Skip("REDIS_ADDRESS not set")
+	}
+
+	redistest.Run(t, func(t *testing.T) redis.Gateway {
+		cfg, err := config.NewYAML(config.Static(map[string]interface{}{
+			"cache": map[string]interface{}{
+				"backend":       redis.BackendRedis,
+				"pubsub_buffer": 100,
+			},
+			"redis": map[string]interface{}{
+				"mode":      redis.ModeSingle,
+				"addresses": []string{addr},
+			},
+		}))
+		if err != nil {
+			t.Fatalf("config: %v", err)
+		}
+
+		lc := fxtest.NewLifecycle(t)
+		g, err := redis.New(redis.Params{
+			Cfg:  cfg,
+			Acfg: aws.Config{},
+			Lc:   lc,
+			Log:  zaptest.NewLogger(t),
+		})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		lc.RequireStart()
+		t.Cleanup(lc.RequireStop)
+		return g
+	})
+}
@@ -0,0 +1,395 @@
+// Package redistest holds the conformance suite every redis.Gateway
+// implementation must pass.
+package redistest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"fx-sample-app/gateway/redis"
+)
+
+// Run exercises a Gateway built by newGateway against the shared semantics.
+// newGateway is called once per subtest so implementations start empty.
+func Run(t *testing.T, newGateway func(t *testing.T) redis.Gateway) {
+	t.Run("GetMissing", func(t *testing.T) {
+		g := newGateway(t)
+		_, err := g.Get(context.Background(), key(t, "missing"))
+		if !errors.Is(err, redis.Nil) {
+			t.Fatalf("Get missing key: got %v, want %v", err, redis.Nil)
+		}
+	})
+
+	t.Run("SetGet", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		k := key(t, "set")
+		if err := g.Set(ctx, k, "value", 0); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		assertValue(t, g, k, "value")
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		k := key(t, "overwrite")
+		if err := g.Set(ctx, k, "first", 0); err != nil {
+			t.Fatalf("Set first: %v", err)
+		}
+		if err := g.Set(ctx, k, "second", 0); err != nil {
+			t.Fatalf("Set second: %v", err)
+		}
+		assertValue(t, g, k, "second")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		k := key(t, "expiry")
+		if err := g.Set(ctx, k, "value", 100*time.Millisecond); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		assertValue(t, g, k, "value")
+
+		time.Sleep(250 * time.Millisecond)
+		_, err := g.Get(ctx, k)
+		if !errors.Is(err, redis.Nil) {
+			t.Fatalf("Get expired key: got %v, want %v", err, redis.Nil)
+		}
+	})
+
+	t.Run("OverwriteClearsExpiry", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		k := key(t, "persist")
+		if err := g.Set(ctx, k, "value", 100*time.Millisecond); err != nil {
+			t.Fatalf("Set with ttl: %v", err)
+		}
+		if err := g.Set(ctx, k, "value", 0); err != nil {
+			t.Fatalf("Set without ttl: %v", err)
+		}
+
+		time.Sleep(250 * time.Millisecond)
+		assertValue(t, g, k, "value")
+	})
+
+	t.Run("LockExclusive", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		name := key(t, "lock")
+		lease, err := g.Acquire(ctx, name, time.Second)
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+		_, err = g.Acquire(ctx, name, time.Second)
+		if !errors.Is(err, redis.ErrNotAcquired) {
+			t.Fatalf("Acquire held lock: got %v, want %v", err, redis.ErrNotAcquired)
+		}
+		if err := g.Release(ctx, lease); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+		next, err := g.Acquire(ctx, name, time.Second)
+		if err != nil {
+			t.Fatalf("Acquire released lock: %v", err)
+		}
+		if next.Fence <= lease.Fence {
+			t.Fatalf("fence did not increase: %d then %d", lease.Fence, next.Fence)
+		}
+	})
+
+	t.Run("LockExpiry", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		name := key(t, "lock")
+		lease, err := g.Acquire(ctx, name, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+
+		time.Sleep(250 * time.Millisecond)
+		if _, err := g.Acquire(ctx, name, time.Second); err != nil {
+			t.Fatalf("Acquire expired lock: %v", err)
+		}
+		if err := g.Renew(ctx, lease, time.Second); !errors.Is(err, redis.ErrLeaseLost) {
+			t.Fatalf("Renew lost lease: got %v, want %v", err, redis.ErrLeaseLost)
+		}
+		if err := g.Release(ctx, lease); !errors.Is(err, redis.ErrLeaseLost) {
+			t.Fatalf("Release lost lease: got %v, want %v", err, redis.ErrLeaseLost)
+		}
+	})
+
+	t.Run("LockRenew", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		name := key(t, "lock")
+		lease, err := g.Acquire(ctx, name, 200*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		if err := g.Renew(ctx, lease, time.Second); err != nil {
+			t.Fatalf("Renew: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+		_, err = g.Acquire(ctx, name, time.Second)
+		if !errors.Is(err, redis.ErrNotAcquired) {
+			t.Fatalf("Acquire renewed lock: got %v, want %v", err, redis.ErrNotAcquired)
+		}
+	})
+
+	t.Run("RateLimit", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		k := key(t, "bucket")
+		limit := redis.Limit{Events: 1, Period: time.Minute, Burst: 2}
+		for i := 0; i < limit.Burst; i++ {
+			res, err := g.Allow(ctx, k, limit)
+			if err != nil {
+				t.Fatalf("Allow %d: %v", i, err)
+			}
+			if !res.Allowed {
+				t.Fatalf("Allow %d within burst: denied", i)
+			}
+		}
+
+		res, err := g.Allow(ctx, k, limit)
+		if err != nil {
+			t.Fatalf("Allow over burst: %v", err)
+		}
+		if res.Allowed || res.RetryAfter <= 0 {
+			t.Fatalf("Allow over burst: got %+v, want denied with retry after", res)
+		}
+		if _, err := g.Allow(ctx, k, redis.Limit{}); err == nil {
+			t.Fatal("Allow with zero limit: got nil error")
+		}
+	})
+
+	t.Run("PubSub", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		channel := key(t, "channel")
+		sub, err := g.Subscribe(ctx, channel)
+		if err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+
+		// Subscribing may complete asynchronously, publish until received.
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		timeout := time.After(2 * time.Second)
+	receive:
+		for {
+			if err := g.Publish(ctx, channel, "hello"); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			select {
+			case msg := <-sub.C:
+				if msg.Channel != channel || msg.Payload != "hello" {
+					t.Fatalf("received %+v", msg)
+				}
+				break receive
+			case <-ticker.C:
+			case <-timeout:
+				t.Fatal("no message received")
+			}
+		}
+
+		if err := sub.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		for range sub.C {
+			// Drain messages published before Close.
+		}
+	})
+
+	t.Run("QueueAck", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		queue := key(t, "queue")
+		jobID, err := g.Enqueue(ctx, queue, redis.Job{Type: "test", Payload: []byte(`{"n":1}`)})
+		if err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+
+		job := dequeueOne(t, g, queue, "a")
+		if job.JobID != jobID || job.Type != "test" || string(job.Payload) != `{"n":1}` {
+			t.Fatalf("Dequeue: got %+v", job)
+		}
+		if err := g.Ack(ctx, queue, job); err != nil {
+			t.Fatalf("Ack: %v", err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		reclaimed, err := g.Reclaim(ctx, queue, "b", time.Millisecond, 10)
+		if err != nil {
+			t.Fatalf("Reclaim: %v", err)
+		}
+		if len(reclaimed) != 0 {
+			t.Fatalf("Reclaim acked job: got %+v", reclaimed)
+		}
+	})
+
+	t.Run("QueueRetry", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		queue := key(t, "queue")
+		if _, err := g.Enqueue(ctx, queue, redis.Job{Type: "test"}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+
+		job := dequeueOne(t, g, queue, "a")
+		job.Attempt++
+		job.LastError = "failed"
+		if err := g.Retry(ctx, queue, job, 100*time.Millisecond); err != nil {
+			t.Fatalf("Retry: %v", err)
+		}
+
+		retried := dequeueOne(t, g, queue, "a")
+		if retried.JobID != job.JobID || retried.Attempt != 1 || retried.LastError != "failed" {
+			t.Fatalf("Dequeue retried: got %+v", retried)
+		}
+	})
+
+	t.Run("QueueDeadLetter", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		queue := key(t, "queue")
+		if _, err := g.Enqueue(ctx, queue, redis.Job{Type: "test"}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+
+		job := dequeueOne(t, g, queue, "a")
+		job.Attempt = 3
+		if err := g.DeadLetter(ctx, queue, job); err != nil {
+			t.Fatalf("DeadLetter: %v", err)
+		}
+		dead, err := g.DeadLetters(ctx, queue, 10)
+		if err != nil {
+			t.Fatalf("DeadLetters: %v", err)
+		}
+		if len(dead) != 1 || dead[0].JobID != job.JobID || dead[0].Attempt != 3 {
+			t.Fatalf("DeadLetters: got %+v", dead)
+		}
+
+		if _, err := g.Replay(ctx, queue, "0-1"); !errors.Is(err, redis.ErrJobNotFound) {
+			t.Fatalf("Replay missing: got %v, want %v", err, redis.ErrJobNotFound)
+		}
+		for _, id := range []string{"", "abc", "1-", "-1", "1-2-3", "+"} {
+			if _, err := g.Replay(ctx, queue, id); !errors.Is(err, redis.ErrInvalidJobID) {
+				t.Fatalf("Replay %q: got %v, want %v", id, err, redis.ErrInvalidJobID)
+			}
+		}
+
+		// Concurrent replays requeue the job once.
+		const replays = 8
+		errs := make(chan error, replays)
+		for i := 0; i < replays; i++ {
+			go func() {
+				_, err := g.Replay(ctx, queue, dead[0].ID)
+				errs <- err
+			}()
+		}
+		var replayed int
+		for i := 0; i < replays; i++ {
+			err := <-errs
+			switch {
+			case err == nil:
+				replayed++
+			case !errors.Is(err, redis.ErrJobNotFound):
+				t.Fatalf("Replay: %v", err)
+			}
+		}
+		if replayed != 1 {
+			t.Fatalf("Replay succeeded %d times, want 1", replayed)
+		}
+		requeued := dequeueOne(t, g, queue, "a")
+		if requeued.JobID != job.JobID || requeued.Attempt != 0 {
+			t.Fatalf("Dequeue replayed: got %+v", requeued)
+		}
+		if more, err := g.Dequeue(ctx, queue, "a", 10, 100*time.Millisecond); err != nil || len(more) != 0 {
+			t.Fatalf("Dequeue after replay: got %+v, %v, want no duplicate", more, err)
+		}
+		dead, err = g.DeadLetters(ctx, queue, 10)
+		if err != nil || len(dead) != 0 {
+			t.Fatalf("DeadLetters after replay: got %+v, %v", dead, err)
+		}
+	})
+
+	t.Run("QueueReclaim", func(t *testing.T) {
+		g := newGateway(t)
+		ctx := context.Background()
+		queue := key(t, "queue")
+		if _, err := g.Enqueue(ctx, queue, redis.Job{Type: "test"}); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+
+		job := dequeueOne(t, g, queue, "crashed")
+		reclaimed, err := g.Reclaim(ctx, queue, "b", time.Minute, 10)
+		if err != nil {
+			t.Fatalf("Reclaim: %v", err)
+		}
+		if len(reclaimed) != 0 {
+			t.Fatalf("Reclaim before idle: got %+v", reclaimed)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+		reclaimed, err = g.Reclaim(ctx, queue, "b", 100*time.Millisecond, 10)
+		if err != nil {
+			t.Fatalf("Reclaim: %v", err)
+		}
+		if len(reclaimed) != 1 || reclaimed[0].ID != job.ID {
+			t.Fatalf("Reclaim idle: got %+v", reclaimed)
+		}
+	})
+
+	t.Run("CanceledContext", func(t *testing.T) {
+		g := newGateway(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := g.Set(ctx, key(t, "canceled"), "value", 0); err == nil {
+			t.Fatal("Set with canceled context: got nil error")
+		}
+	})
+}
+
+// key namespaces keys per test so a shared server can be reused.
+func key(t *testing.T, name string) string {
+	return fmt.Sprintf("redistest:%s:%s:%d", t.Name(), name, time.Now().UnixNano())
+}
+
+// dequeueOne waits for a single job on queue.
+func dequeueOne(t *testing.T, g redis.Gateway, queue, consumer string) redis.Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		jobs, err := g.Dequeue(context.Background(), queue, consumer, 1, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Dequeue: %v", err)
+		}
+		if len(jobs) == 1 {
+			return jobs[0]
+		}
+		if len(jobs) > 1 {
+			t.Fatalf("Dequeue count 1: got %d jobs", len(jobs))
+		}
+	}
+	t.Fatal("Dequeue: no job before deadline")
+	return redis.Job{}
+}
+
+// assertValue fails the test unless key holds want.
+func assertValue(t *testing.T, g redis.Gateway, key, want string) {
+	t.Helper()
+	got, err := g.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	if got != want {
+		t.Fatalf("Get %s: got %q, want %q", key, got, want)
+	}
+}
@@ -1,7 +1,10 @@
 package slack
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/slack-go/slack"
 	"go.uber.org/config"
@@ -9,19 +12,27 @@ import (
 
 type Gateway interface {
 	GetSigningKey() string
+	RespondToCommand(ctx context.Context, responseURL, text string) error
+	Notify(ctx context.Context, webhookURL, text string) error
 }
 
+// webhookTimeout bounds each webhook post.
+const webhookTimeout = 10 * time.Second
+
 type gateway struct {
 	client *slack.Client
-	macKey string
+	// webhooks posts to webhooks, which the default client never times out.
+	webhooks *http.Client
+	macKey   string
 }
 
 func New(cfg config.Provider) Gateway {
 	token := cfg.Get("slack.token").String()
 	signingKey := cfg.Get("slack.signing_key").String()
 	return &gateway{
-		client: slack.New(token),
-		macKey: signingKey,
+		client:   slack.New(token),
+		webhooks: &http.Client{Timeout: webhookTimeout},
+		macKey:   signingKey,
 	}
 }
 
@@ -29,6 +40,28 @@ func (g *gateway) GetSigningKey() string {
 	return g.macKey
 }
 
+// RespondToCommand posts a delayed reply to a slash command's response url.
+func (g *gateway) RespondToCommand(ctx context.Context, responseURL, text string) error {
+	err := slack.PostWebhookCustomHTTPContext(ctx, responseURL, g.webhooks, &slack.WebhookMessage{
+		Text: text,
+	})
+	if err != nil {
+		return fmt.Errorf("post webhook %w", err)
+	}
+	return nil
+}
+
+// Notify posts text to an incoming webhook's channel.
+func (g *gateway) Notify(ctx context.Context, webhookURL, text string) error {
+	err := slack.PostWebhookCustomHTTPContext(ctx, webhookURL, g.webhooks, &slack.WebhookMessage{
+		Text: text,
+	})
+	if err != nil {
+		return fmt.Errorf("post webhook %w", err)
+	}
+	return nil
+}
+
 func (g *gateway) ParseSlashCmd(r http.Request) SlashCommand {
 	return SlashCommand{
 		Token:          r.FormValue("token"),
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"fx-sample-app/gateway/redis"
+	pb "fx-sample-app/proto/fxsample"
+)
+
+// defaultDeadJobsLimit applies when ListDeadJobs has no limit.
+const defaultDeadJobsLimit = 100
+
+// adminMethods are the RPCs only callers listed in auth.admins may make.
+var adminMethods = map[string]bool{
+	pb.Fxsample_ListDeadJobs_FullMethodName:  true,
+	pb.Fxsample_ReplayDeadJob_FullMethodName: true,
+}
+
+// ListDeadJobs lists jobs parked in the dead letter queue.
+func (h *Handlers) ListDeadJobs(
+	ctx context.Context,
+	req *pb.ListDeadJobsRequest,
+) (*pb.ListDeadJobsResponse, error) {
+	limit := int(req.Limit)
+	if limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	if limit == 0 {
+		limit = defaultDeadJobsLimit
+	}
+
+	dead, err := h.jobs.DeadLetters(ctx, limit)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "dead letters: %v", err)
+	}
+
+	resp := &pb.ListDeadJobsResponse{}
+	for _, job := range dead {
+		resp.Jobs = append(resp.Jobs, &pb.DeadJob{
+			Id:         job.ID,
+			JobId:      job.JobID,
+			Type:       job.Type,
+			Payload:    string(job.Payload),
+			Attempt:    int32(job.Attempt),
+			LastError:  job.LastError,
+			EnqueuedAt: job.EnqueuedAt.Unix(),
+		})
+	}
+
+	return resp, nil
+}
+
+// ReplayDeadJob moves a dead lettered job back onto the queue.
+func (h *Handlers) ReplayDeadJob(
+	ctx context.Context,
+	req *pb.ReplayDeadJobRequest,
+) (*pb.ReplayDeadJobResponse, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	job, err := h.jobs.Replay(ctx, req.Id)
+	if errors.Is(err, redis.ErrInvalidJobID) {
+		return nil, status.Errorf(codes.InvalidArgument, "id %q is not a dead job id", req.Id)
+	}
+	if errors.Is(err, redis.ErrJobNotFound) {
+		return nil, status.Errorf(codes.NotFound, "dead job %s not found", req.Id)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "replay: %v", err)
+	}
+
+	return &pb.ReplayDeadJobResponse{
+		JobId: job.JobID,
+	}, nil
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authConfig defines settings under the auth key.
+type authConfig struct {
+	// APIKeys maps caller names to the keys they send as X-Api-Key.
+	APIKeys map[string]string `yaml:"api_keys"`
+	// Admins are the caller names allowed the admin RPCs.
+	Admins []string `yaml:"admins"`
+}
+
+// callerKey is the context key of an authenticated caller's name.
+type callerKey struct{}
+
+// authenticator checks API keys against the configured ones.
+type authenticator struct {
+	// sums are the key hashes by caller name, compared in constant time.
+	sums map[string][sha256.Size]byte
+	// admins are the caller names allowed adminMethods.
+	admins map[string]bool
+}
+
+// newAuthenticator is the authenticator constructor.
+func newAuthenticator(cfg authConfig) (*authenticator, error) {
+	a := &authenticator{
+		sums:   make(map[string][sha256.Size]byte, len(cfg.APIKeys)),
+		admins: make(map[string]bool, len(cfg.Admins)),
+	}
+	for name, key := range cfg.APIKeys {
+		if name == "" || key == "" {
+			return nil, fmt.Errorf("api_keys %q must have a name and key", name)
+		}
+		a.sums[name] = sha256.Sum256([]byte(key))
+	}
+	for _, name := range cfg.Admins {
+		if _, ok := a.sums[name]; !ok {
+			return nil, fmt.Errorf("admin %q has no api key", name)
+		}
+		a.admins[name] = true
+	}
+	return a, nil
+}
+
+// verify returns the name of the caller key belongs to.
+func (a *authenticator) verify(key string) (string, bool) {
+	sum := sha256.Sum256([]byte(key))
+	var caller string
+	for name, want := range a.sums {
+		if subtle.ConstantTimeCompare(sum[:], want[:]) == 1 {
+			caller = name
+		}
+	}
+	return caller, caller != ""
+}
+
+// authenticate returns ctx carrying the caller of a valid API key. An
+// unknown key is rejected, calls without one are anonymous.
+func (a *authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(apiKeyHeader)
+	if len(keys) == 0 || keys[0] == "" {
+		return ctx, nil
+	}
+	caller, ok := a.verify(keys[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid api key")
+	}
+	return context.WithValue(ctx, callerKey{}, caller), nil
+}
+
+// authorize rejects calls of adminMethods by callers other than admins.
+func (a *authenticator) authorize(ctx context.Context, method string) error {
+	if !adminMethods[method] {
+		return nil
+	}
+	caller, ok := authCaller(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "an admin api key is required")
+	}
+	if !a.admins[caller] {
+		return status.Errorf(codes.PermissionDenied, "%s is not an admin", caller)
+	}
+	return nil
+}
+
+// authCaller returns the authenticated caller's name, false for
+// anonymous calls.
+func authCaller(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(callerKey{}).(string)
+	return caller, ok
+}
+
+// unary authenticates and authorizes gRPC calls, also covering REST
+// calls through the proxy.
+func (a *authenticator) unary(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// stream authenticates and authorizes streaming calls.
+func (a *authenticator) stream(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	err = a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, actorServerStream{ServerStream: ss, ctx: ctx})
+}
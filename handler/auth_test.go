@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "fx-sample-app/proto/fxsample"
+)
+
+func TestAuthenticate(t *testing.T) {
+	a, err := newAuthenticator(authConfig{APIKeys: map[string]string{
+		"ci":    "ci-key",
+		"admin": "admin-key",
+	}})
+	if err != nil {
+		t.Fatalf("newAuthenticator: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		md     metadata.MD
+		caller string
+		code   codes.Code
+	}{
+		{"valid key", metadata.Pairs(apiKeyHeader, "ci-key"), "key:ci", codes.OK},
+		{"other key", metadata.Pairs(apiKeyHeader, "admin-key"), "key:admin", codes.OK},
+		{"unknown key", metadata.Pairs(apiKeyHeader, "random"), "", codes.Unauthenticated},
+		{"no key", nil, "ip:", codes.OK},
+		{"empty key", metadata.Pairs(apiKeyHeader, ""), "ip:", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := a.authenticate(metadata.NewIncomingContext(context.Background(), tt.md))
+			if status.Code(err) != tt.code {
+				t.Fatalf("authenticate: got %v, want %s", err, tt.code)
+			}
+			if err != nil {
+				return
+			}
+			if got := grpcCaller(ctx); got != tt.caller {
+				t.Fatalf("grpcCaller: got %q, want %q", got, tt.caller)
+			}
+		})
+	}
+}
+
+func TestAuditActor(t *testing.T) {
+	a, err := newAuthenticator(authConfig{APIKeys: map[string]string{"ci": "ci-key"}})
+	if err != nil {
+		t.Fatalf("newAuthenticator: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want string
+	}{
+		{"valid key", metadata.Pairs(apiKeyHeader, "ci-key"), "key:ci"},
+		{"no key", nil, unauthenticatedActor},
+		{"forwarded address", metadata.Pairs("x-forwarded-for", "198.51.100.7"), unauthenticatedActor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := a.authenticate(metadata.NewIncomingContext(context.Background(), tt.md))
+			if err != nil {
+				t.Fatalf("authenticate: %v", err)
+			}
+			if got := auditActor(ctx); got != tt.want {
+				t.Fatalf("auditActor: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticatorRejectsEmptyKeys(t *testing.T) {
+	_, err := newAuthenticator(authConfig{APIKeys: map[string]string{"ci": ""}})
+	if err == nil {
+		t.Fatal("newAuthenticator accepted an empty key")
+	}
+}
+
+func TestAdminMethods(t *testing.T) {
+	a, err := newAuthenticator(authConfig{
+		APIKeys: map[string]string{"ops": "ops-key", "ci": "ci-key"},
+		Admins:  []string{"ops"},
+	})
+	if err != nil {
+		t.Fatalf("newAuthenticator: %v", err)
+	}
+	ok := func(context.Context, interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		md     metadata.MD
+		code   codes.Code
+	}{
+		{"admin replays", pb.Fxsample_ReplayDeadJob_FullMethodName, metadata.Pairs(apiKeyHeader, "ops-key"), codes.OK},
+		{"admin lists", pb.Fxsample_ListDeadJobs_FullMethodName, metadata.Pairs(apiKeyHeader, "ops-key"), codes.OK},
+		{"other key", pb.Fxsample_ReplayDeadJob_FullMethodName, metadata.Pairs(apiKeyHeader, "ci-key"), codes.PermissionDenied},
+		{"anonymous", pb.Fxsample_ListDeadJobs_FullMethodName, nil, codes.Unauthenticated},
+		{"unknown key", pb.Fxsample_ReplayDeadJob_FullMethodName, metadata.Pairs(apiKeyHeader, "guess"), codes.Unauthenticated},
+		{"anonymous other method", pb.Fxsample_GetPerson_FullMethodName, nil, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			_, err := a.unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, ok)
+			if status.Code(err) != tt.code {
+				t.Fatalf("unary: got %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticatorRejectsUnknownAdmins(t *testing.T) {
+	_, err := newAuthenticator(authConfig{Admins: []string{"ops"}})
+	if err == nil {
+		t.Fatal("newAuthenticator accepted an admin without a key")
+	}
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "fx-sample-app/proto/fxsample"
+	"fx-sample-app/records"
+)
+
+// exportChunkSize is the size of streamed export chunks.
+const exportChunkSize = 32 << 10
+
+// importStream is the server side of an import RPC.
+type importStream interface {
+	Context() context.Context
+	Recv() (*pb.ImportRequest, error)
+	SendAndClose(*pb.ImportResponse) error
+}
+
+// exportStream is the server side of an export RPC.
+type exportStream interface {
+	Context() context.Context
+	Send(*pb.ExportChunk) error
+}
+
+// importFunc stores records read from r.
+type importFunc func(
+	ctx context.Context,
+	r io.Reader,
+	format records.Format,
+	upsert bool,
+) (int64, error)
+
+// exportFunc writes every record to w.
+type exportFunc func(ctx context.Context, w io.Writer, format records.Format) error
+
+// ImportPeople stores people streamed as a CSV or NDJSON file.
+func (h *Handlers) ImportPeople(stream pb.Fxsample_ImportPeopleServer) error {
+	return importFile(stream, h.con.ImportPeople, "import people")
+}
+
+// ExportPeople streams every person as a CSV or NDJSON file.
+func (h *Handlers) ExportPeople(
+	req *pb.ExportRequest,
+	stream pb.Fxsample_ExportPeopleServer,
+) error {
+	return exportFile(req, stream, h.con.ExportPeople, "export people")
+}
+
+// ImportPlaces stores places streamed as a CSV or NDJSON file.
+func (h *Handlers) ImportPlaces(stream pb.Fxsample_ImportPlacesServer) error {
+	return importFile(stream, h.con.ImportPlaces, "import places")
+}
+
+// ExportPlaces streams every place as a CSV or NDJSON file.
+func (h *Handlers) ExportPlaces(
+	req *pb.ExportRequest,
+	stream pb.Fxsample_ExportPlacesServer,
+) error {
+	return exportFile(req, stream, h.con.ExportPlaces, "export places")
+}
+
+// importFile pipes the streamed chunks into fn.
+func importFile(stream importStream, fn importFunc, action string) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return invalidf("no data")
+	}
+	if err != nil {
+		return err
+	}
+	format, err := formatFromPB(first.Format)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		req := first
+		for {
+			_, err := pw.Write(req.Data)
+			if err != nil {
+				return
+			}
+			req, err = stream.Recv()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	imported, err := fn(ctx, pr, format, first.Upsert)
+	// Unblocks the receiver when the import stopped early.
+	pr.CloseWithError(errors.New("import ended"))
+	var importErr *records.ImportError
+	if errors.As(err, &importErr) {
+		return status.Errorf(codes.InvalidArgument, "%s: %v", action, importErr)
+	}
+	if err != nil {
+		return dbStatus(ctx, err, action)
+	}
+
+	return stream.SendAndClose(&pb.ImportResponse{
+		Imported: imported,
+	})
+}
+
+// exportFile streams the output of fn in chunks.
+func exportFile(req *pb.ExportRequest, stream exportStream, fn exportFunc, action string) error {
+	ctx := showDeleted(stream.Context(), req.ShowDeleted)
+	format, err := formatFromPB(req.Format)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(chunkWriter{stream}, exportChunkSize)
+	err = fn(ctx, w, format)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return dbStatus(ctx, err, action)
+	}
+	return nil
+}
+
+// chunkWriter sends each write as an export chunk.
+type chunkWriter struct {
+	stream exportStream
+}
+
+// Write implements io.Writer.
+func (c chunkWriter) Write(p []byte) (int, error) {
+	err := c.stream.Send(&pb.ExportChunk{
+		Data: p,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// formatFromPB converts an API format, which must be set.
+func formatFromPB(format pb.Format) (records.Format, error) {
+	switch format {
+	case pb.Format_FORMAT_CSV:
+		return records.CSV, nil
+	case pb.Format_FORMAT_NDJSON:
+		return records.NDJSON, nil
+	}
+	return 0, invalidf("format must be FORMAT_CSV or FORMAT_NDJSON")
+}
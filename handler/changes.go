@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+
+	"fx-sample-app/gateway/postgres"
+	pb "fx-sample-app/proto/fxsample"
+	"fx-sample-app/records"
+)
+
+// watchable are the entities with a change feed.
+var watchable = map[string]bool{"people": true, "places": true}
+
+// WatchChanges streams committed changes to people and places.
+func (h *Handlers) WatchChanges(
+	req *pb.WatchChangesRequest,
+	stream pb.Fxsample_WatchChangesServer,
+) error {
+	var tables []string
+	for _, entity := range req.Entities {
+		if !watchable[entity] {
+			return invalidf("entities must be people or places")
+		}
+		tables = append(tables, records.Entities[entity])
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return h.con.WatchChanges(ctx, tables, func(c postgres.Change) error {
+		return stream.Send(records.ChangeToPB(c))
+	})
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+// ifMatchHeader carries the ETag an HTTP change is conditioned on.
+const ifMatchHeader = "if-match"
+
+// versioned is a message with a row version.
+type versioned interface {
+	GetVersion() int64
+}
+
+// precondition returns ctx making changes conditional on version, or
+// on the If-Match header of HTTP calls. Both must agree when set.
+func precondition(ctx context.Context, version int64) (context.Context, error) {
+	if version < 0 {
+		return nil, invalidf("version must not be negative")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(ifMatchHeader); len(values) > 0 {
+		matched, err := parseETag(strings.Join(values, ","))
+		if err != nil {
+			return nil, err
+		}
+		if version != 0 && matched != 0 && version != matched {
+			return nil, invalidf("version %d does not match If-Match", version)
+		}
+		if version == 0 {
+			version = matched
+		}
+	}
+
+	if version == 0 {
+		return ctx, nil
+	}
+	return postgres.IfVersion(ctx, version), nil
+}
+
+// etag returns the strong ETag of a version.
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parseETag returns the version of an If-Match value, zero for "*".
+// Only a single strong ETag is accepted.
+func parseETag(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "*" {
+		return 0, nil
+	}
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return 0, invalidf("If-Match must be a single strong ETag")
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, invalidf("If-Match %s is not an ETag of this server", value)
+	}
+	return version, nil
+}
+
+// setETag returns the version of versioned HTTP responses as their ETag.
+func setETag(ctx context.Context, w http.ResponseWriter, m proto.Message) error {
+	if v, ok := m.(versioned); ok && v.GetVersion() > 0 {
+		w.Header().Set("ETag", etag(v.GetVersion()))
+	}
+	return nil
+}
+
+// httpError reports a failed If-Match as 412 Precondition Failed rather
+// than the 409 Conflict of ABORTED, and other errors as the gateway does.
+func httpError(
+	ctx context.Context,
+	mux *runtime.ServeMux,
+	marshaler runtime.Marshaler,
+	w http.ResponseWriter,
+	r *http.Request,
+	err error,
+) {
+	if status.Code(err) == codes.Aborted && r.Header.Get("If-Match") != "" {
+		w = statusWriter{ResponseWriter: w, status: http.StatusPreconditionFailed}
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+}
+
+// statusWriter replaces the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader writes the replacement status code.
+func (w statusWriter) WriteHeader(int) {
+	w.ResponseWriter.WriteHeader(w.status)
+}
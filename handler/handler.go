@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
@@ -25,33 +26,58 @@ import (
 	"google.golang.org/grpc/reflection"
 
 	"fx-sample-app/controller"
+	"fx-sample-app/gateway/postgres"
+	"fx-sample-app/gateway/redis"
+	"fx-sample-app/jobs"
 	pb "fx-sample-app/proto/fxsample"
 )
 
+// slackCommandsPath receives slack slash commands.
+const slackCommandsPath = "/slack/commands"
+
+// metricsPath serves query latency histograms.
+const metricsPath = "/metrics"
+
+// leaderService is the health check service reporting leadership.
+// SERVING on the elected replica, NOT_SERVING elsewhere.
+const leaderService = "fxsample.leader"
+
 // Handlers implements grpc service.
 type Handlers struct {
 	pb.UnimplementedFxsampleServer
 
 	log    *zap.Logger
 	con    controller.Controller
+	jobs   jobs.Queue
 	health *health.Server
+	pages  *pager
+	// limiter limits slack commands once their signature is verified.
+	limiter *rateLimiter
+	// stopping is closed on shutdown to end open streams.
+	stopping chan struct{}
 }
 
 // Params defines constructor requirements.
 type Params struct {
 	fx.In
 
-	Log *zap.Logger
-	Lc  fx.Lifecycle
-	Cfg config.Provider
-	Con controller.Controller
+	Log     *zap.Logger
+	Lc      fx.Lifecycle
+	Cfg     config.Provider
+	Con     controller.Controller
+	Jobs    jobs.Queue
+	Cache   redis.Gateway
+	Elector *redis.Elector
+	Stats   *postgres.QueryStats
 }
 
 // New is the handler constructor.
 func New(p Params) (*Handlers, error) {
 	h := &Handlers{
-		log: p.Log,
-		con: p.Con,
+		log:      p.Log,
+		con:      p.Con,
+		jobs:     p.Jobs,
+		stopping: make(chan struct{}),
 	}
 	ln, err := net.Listen(
 		"tcp",
@@ -61,8 +87,53 @@ func New(p Params) (*Handlers, error) {
 		return nil, fmt.Errorf("grpc net listen %w", err)
 	}
 
+	var authCfg authConfig
+	err = p.Cfg.Get("auth").Populate(&authCfg)
+	if err != nil {
+		return nil, fmt.Errorf("auth config %w", err)
+	}
+	auth, err := newAuthenticator(authCfg)
+	if err != nil {
+		return nil, fmt.Errorf("auth config %w", err)
+	}
+
+	var rlCfg rateLimitConfig
+	err = p.Cfg.Get("ratelimit").Populate(&rlCfg)
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit config %w", err)
+	}
+	limiter := &rateLimiter{
+		cache: p.Cache,
+		cfg:   rlCfg,
+		log:   p.Log,
+	}
+	h.limiter = limiter
+
+	var idemCfg idempotencyConfig
+	err = p.Cfg.Get("idempotency").Populate(&idemCfg)
+	if err != nil {
+		return nil, fmt.Errorf("idempotency config %w", err)
+	}
+	if idemCfg.LockTTL <= 0 {
+		return nil, fmt.Errorf("idempotency lock_ttl must be positive")
+	}
+	idem := newIdempotency(p.Cache, idemCfg, p.Log)
+
+	var pageCfg paginationConfig
+	err = p.Cfg.Get("pagination").Populate(&pageCfg)
+	if err != nil {
+		return nil, fmt.Errorf("pagination config %w", err)
+	}
+	h.pages, err = newPager(pageCfg)
+	if err != nil {
+		return nil, fmt.Errorf("pagination config %w", err)
+	}
+
 	// Create grpc server.
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(auth.unary, limiter.unary, actorUnary, primaryUnary, idem.unary),
+		grpc.ChainStreamInterceptor(auth.stream, actorStream, primaryStream),
+	)
 
 	// Add reflection to service stack.
 	reflection.Register(grpcServer)
@@ -72,6 +143,10 @@ func New(p Params) (*Handlers, error) {
 	healthpb.RegisterHealthServer(grpcServer, healthCheck)
 	h.health = healthCheck
 
+	// Surface leadership changes through the health service.
+	h.setLeaderStatus(p.Elector.IsLeader())
+	p.Elector.OnChange(h.setLeaderStatus)
+
 	// Add sample proto service to service stack.
 	pb.RegisterFxsampleServer(grpcServer, h)
 
@@ -81,13 +156,20 @@ func New(p Params) (*Handlers, error) {
 		p.Cfg.Get("server.address").String(),
 		// grpc.WithBlock(),
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(proxyUnary),
+		grpc.WithStreamInterceptor(proxyStream),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("grpc dial context %w", err)
 	}
 
 	// Create proxy.
-	gwmux := runtime.NewServeMux()
+	gwmux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(incomingHeaders),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaders),
+		runtime.WithForwardResponseOption(setETag),
+		runtime.WithErrorHandler(httpError),
+	)
 	// Register proxy handlers. Routes http calls to gRPC.
 	err = pb.RegisterFxsampleHandler(
 		context.Background(),
@@ -98,9 +180,15 @@ func New(p Params) (*Handlers, error) {
 		return nil, fmt.Errorf("register proxy handler %w", err)
 	}
 
+	// Serve slack commands and metrics alongside the proxied API.
+	mux := http.NewServeMux()
+	mux.HandleFunc(slackCommandsPath, h.catsAAS)
+	mux.Handle(metricsPath, p.Stats)
+	mux.Handle("/", gwmux)
+
 	gwServer := &http.Server{
 		Addr:    "127.0.0.1:8090",
-		Handler: gwmux,
+		Handler: mux,
 	}
 
 	p.Lc.Append(fx.Hook{
@@ -131,6 +219,7 @@ func New(p Params) (*Handlers, error) {
 		},
 		OnStop: func(ctx context.Context) error {
 			h.log.Info("shutting down")
+			close(h.stopping)
 			grpcServer.GracefulStop()
 			gwServer.Shutdown(ctx)
 			return nil
@@ -140,6 +229,15 @@ func New(p Params) (*Handlers, error) {
 	return h, nil
 }
 
+// setLeaderStatus reports leadership on the leader health service.
+func (h *Handlers) setLeaderStatus(leader bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if leader {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	h.health.SetServingStatus(leaderService, status)
+}
+
 // Hello .
 func (h *Handlers) Hello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloResponse, error) {
 	return &pb.HelloResponse{
@@ -162,6 +260,28 @@ func (h *Handlers) CatFact(
 	}, nil
 }
 
+// WatchCatFacts streams cat facts fetched by any instance.
+func (h *Handlers) WatchCatFacts(
+	req *pb.WatchCatFactsRequest,
+	stream pb.Fxsample_WatchCatFactsServer,
+) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return h.con.WatchFacts(ctx, func(fact string) error {
+		return stream.Send(&pb.CatFactResponse{
+			Fact: fact,
+		})
+	})
+}
+
 func (h *Handlers) catsAAS(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	bodyBytes, err := io.ReadAll(r.Body)
@@ -222,20 +342,56 @@ func (h *Handlers) catsAAS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Collect cat fact.
-	catFact, err := h.con.CatFact(ctx)
+	// Limit by slack user only now the signature shows slack sent it.
+	if !h.limiter.allowHTTP(w, r, slackCommandsPath, "slack:"+slash.UserID) {
+		return
+	}
+
+	// Slack expects a reply within 3 seconds, follow up with the fact.
+	_, err = h.jobs.Enqueue(ctx, controller.SlackFollowUpJob, controller.SlackFollowUp{
+		ResponseURL: slash.ResponseURL,
+	})
 	if err != nil {
-		fmt.Println("controller.Cat", err)
+		fmt.Println("jobs.Enqueue", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	// Respond with cat fact.
+	// Acknowledge the command.
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(catFact))
+	w.Write([]byte("Fetching a cat fact..."))
 	return
 }
 
+// incomingHeaders forwards the API key, idempotency key, If-Match and
+// X-Read-Primary headers to gRPC metadata.
+func incomingHeaders(key string) (string, bool) {
+	if strings.EqualFold(key, apiKeyHeader) {
+		return apiKeyHeader, true
+	}
+	if strings.EqualFold(key, idempotencyHeader) {
+		return idempotencyHeader, true
+	}
+	if strings.EqualFold(key, ifMatchHeader) {
+		return ifMatchHeader, true
+	}
+	if strings.EqualFold(key, readPrimaryHeader) {
+		return readPrimaryHeader, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeaders returns Retry-After and Idempotent-Replayed as standard HTTP headers.
+func outgoingHeaders(key string) (string, bool) {
+	switch key {
+	case "retry-after":
+		return "Retry-After", true
+	case replayedHeader:
+		return "Idempotent-Replayed", true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
 // validMAC reports whether messageMAC is a valid HMAC tag for message.
 func validMAC(message, messageMAC, key []byte) bool {
 	mac := hmac.New(sha256.New, key)
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"fx-sample-app/gateway/postgres"
+	pb "fx-sample-app/proto/fxsample"
+	"fx-sample-app/records"
+)
+
+// historySortable are the fields a history listing may be ordered by.
+var historySortable = []string{"id"}
+
+// ListHistory returns a page of the recorded changes to an entity.
+func (h *Handlers) ListHistory(
+	ctx context.Context,
+	req *pb.ListHistoryRequest,
+) (*pb.ListHistoryResponse, error) {
+	entity, ok := records.Entities[req.Entity]
+	if !ok {
+		return nil, invalidf("entity must be people, places or relationships")
+	}
+	err := requireID(req.EntityId)
+	if err != nil {
+		return nil, err
+	}
+	resource := "history?" + url.Values{
+		"entity":    {req.Entity},
+		"entity_id": {req.EntityId},
+	}.Encode()
+	page, err := h.pages.page(resource, historySortable, req.PageSize, req.PageToken, req.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, more, err := h.con.ListHistory(ctx, entity, req.EntityId, page)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "list history")
+	}
+
+	resp := &pb.ListHistoryResponse{}
+	for _, e := range entries {
+		entry, err := records.AuditEntryToPB(e)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list history: %v", err)
+		}
+		resp.Entries = append(resp.Entries, entry)
+	}
+	if more {
+		resp.NextPageToken, err = h.pages.next(resource, page, entries[len(entries)-1])
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list history: %v", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// showDeleted returns ctx including soft deleted rows when show is set.
+func showDeleted(ctx context.Context, show bool) context.Context {
+	if show {
+		return postgres.WithDeleted(ctx)
+	}
+	return ctx
+}
+
+// unauthenticatedActor audits changes by callers without an API key.
+// Their address is not recorded, since clients can choose it.
+const unauthenticatedActor = "unauthenticated"
+
+// auditActor returns who a change is audited as made by, the verified
+// API key's caller name or unauthenticatedActor.
+func auditActor(ctx context.Context) string {
+	if caller, ok := authCaller(ctx); ok {
+		return "key:" + caller
+	}
+	return unauthenticatedActor
+}
+
+// actorUnary audits changes as made by the caller.
+func actorUnary(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	return handler(postgres.WithActor(ctx, auditActor(ctx)), req)
+}
+
+// actorStream audits changes made by streaming calls as made by the caller.
+func actorStream(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx := postgres.WithActor(ss.Context(), auditActor(ss.Context()))
+	return handler(srv, actorServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// actorServerStream replaces the context of a stream.
+type actorServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream context carrying the actor.
+func (s actorServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"fx-sample-app/gateway/redis"
+)
+
+const (
+	// idempotencyHeader carries the client chosen key on HTTP and gRPC.
+	idempotencyHeader = "idempotency-key"
+	// replayedHeader marks responses served from a stored result.
+	replayedHeader = "idempotent-replayed"
+	// maxIdempotencyKey bounds key length.
+	maxIdempotencyKey = 255
+	// idempotencyWriteTimeout bounds storing a result and releasing the
+	// lock, which outlive a canceled request.
+	idempotencyWriteTimeout = 5 * time.Second
+)
+
+// idempotencyConfig defines settings under the idempotency key.
+type idempotencyConfig struct {
+	// TTL is how long a result is replayed for.
+	TTL time.Duration `yaml:"ttl"`
+	// LockTTL bounds how long a crashed request blocks duplicates, the
+	// lock is renewed while the request runs.
+	LockTTL time.Duration `yaml:"lock_ttl"`
+	// Methods lists the mutating gRPC full method names keys apply to.
+	Methods []string `yaml:"methods"`
+}
+
+// idempotencyRecord is the stored outcome of the first request.
+type idempotencyRecord struct {
+	Hash     string     `json:"hash"`
+	Code     codes.Code `json:"code"`
+	Message  string     `json:"message,omitempty"`
+	Response []byte     `json:"response,omitempty"`
+}
+
+// idempotency replays stored results for repeated Idempotency-Key requests.
+type idempotency struct {
+	cache   redis.Gateway
+	cfg     idempotencyConfig
+	methods map[string]struct{}
+	log     *zap.Logger
+}
+
+// newIdempotency is the idempotency constructor.
+func newIdempotency(cache redis.Gateway, cfg idempotencyConfig, log *zap.Logger) *idempotency {
+	methods := make(map[string]struct{}, len(cfg.Methods))
+	for _, method := range cfg.Methods {
+		methods[method] = struct{}{}
+	}
+	return &idempotency{
+		cache:   cache,
+		cfg:     cfg,
+		methods: methods,
+		log:     log,
+	}
+}
+
+// unary is the gRPC interceptor, also covering REST calls through the proxy.
+func (i *idempotency) unary(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if _, ok := i.methods[info.FullMethod]; !ok {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(idempotencyHeader)
+	if len(keys) == 0 || keys[0] == "" {
+		return handler(ctx, req)
+	}
+	if len(keys[0]) > maxIdempotencyKey {
+		return nil, status.Errorf(
+			codes.InvalidArgument,
+			"idempotency key longer than %d characters",
+			maxIdempotencyKey,
+		)
+	}
+
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return handler(ctx, req)
+	}
+	hash, err := fingerprint(msg)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "fingerprint request: %v", err)
+	}
+
+	// Keys are scoped per method and caller so clients cannot collide.
+	storeKey := fmt.Sprintf("idempotency:%s:%s:%s", info.FullMethod, grpcCaller(ctx), keys[0])
+	if resp, ok, err := i.replay(ctx, storeKey, hash); ok {
+		return resp, err
+	}
+
+	lease, err := i.cache.Acquire(ctx, storeKey, i.cfg.LockTTL)
+	if errors.Is(err, redis.ErrNotAcquired) {
+		return nil, status.Error(
+			codes.Aborted,
+			"a request with this idempotency key is in progress",
+		)
+	}
+	if err != nil {
+		i.log.Error("idempotency lock", zap.Error(err))
+		return nil, status.Error(codes.Unavailable, "idempotency store unavailable")
+	}
+	stop := i.hold(ctx, lease)
+	defer func() {
+		stop()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyWriteTimeout)
+		defer cancel()
+		err := i.cache.Release(ctx, lease)
+		if err != nil && !errors.Is(err, redis.ErrLeaseLost) {
+			i.log.Error("idempotency unlock", zap.Error(err))
+		}
+	}()
+
+	// A duplicate may have finished between the first check and the lock.
+	if resp, ok, err := i.replay(ctx, storeKey, hash); ok {
+		return resp, err
+	}
+
+	resp, handlerErr := handler(ctx, req)
+	i.store(ctx, storeKey, hash, resp, handlerErr)
+
+	return resp, handlerErr
+}
+
+// hold renews lease until the returned stop is called, so requests
+// running past the lock ttl keep blocking duplicates.
+func (i *idempotency) hold(ctx context.Context, lease redis.Lease) (stop func()) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(i.cfg.LockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := i.cache.Renew(ctx, lease, i.cfg.LockTTL)
+			if errors.Is(err, redis.ErrLeaseLost) {
+				i.log.Warn("idempotency lock lost", zap.String("key", lease.Key))
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				i.log.Error("idempotency renew", zap.Error(err))
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// replay returns the stored outcome for storeKey, ok false when none exists.
+func (i *idempotency) replay(
+	ctx context.Context,
+	storeKey, hash string,
+) (resp interface{}, ok bool, err error) {
+	raw, err := i.cache.Get(ctx, storeKey)
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		i.log.Error("idempotency get", zap.Error(err))
+		return nil, true, status.Error(codes.Unavailable, "idempotency store unavailable")
+	}
+
+	var rec idempotencyRecord
+	err = json.Unmarshal([]byte(raw), &rec)
+	if err != nil {
+		return nil, true, status.Errorf(codes.Internal, "unmarshal idempotency record: %v", err)
+	}
+	if rec.Hash != hash {
+		return nil, true, status.Error(
+			codes.InvalidArgument,
+			"idempotency key reused with a different request",
+		)
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(replayedHeader, "true"))
+	if rec.Code != codes.OK {
+		return nil, true, status.Error(rec.Code, rec.Message)
+	}
+
+	var stored anypb.Any
+	err = proto.Unmarshal(rec.Response, &stored)
+	if err != nil {
+		return nil, true, status.Errorf(codes.Internal, "unmarshal stored response: %v", err)
+	}
+	msg, err := stored.UnmarshalNew()
+	if err != nil {
+		return nil, true, status.Errorf(codes.Internal, "unmarshal stored response: %v", err)
+	}
+
+	return msg, true, nil
+}
+
+// store saves a final outcome, even when the caller has gone away.
+// Transient failures are not stored so retrying with the same key runs
+// the request again.
+func (i *idempotency) store(
+	ctx context.Context,
+	storeKey, hash string,
+	resp interface{},
+	handlerErr error,
+) {
+	rec := idempotencyRecord{
+		Hash: hash,
+	}
+	if handlerErr != nil {
+		st := status.Convert(handlerErr)
+		if retryable(st.Code()) {
+			return
+		}
+		rec.Code = st.Code()
+		rec.Message = st.Message()
+	} else {
+		msg, ok := resp.(proto.Message)
+		if !ok {
+			return
+		}
+		stored, err := anypb.New(msg)
+		if err != nil {
+			i.log.Error("idempotency wrap response", zap.Error(err))
+			return
+		}
+		rec.Response, err = proto.Marshal(stored)
+		if err != nil {
+			i.log.Error("idempotency marshal response", zap.Error(err))
+			return
+		}
+	}
+
+	recBytes, err := json.Marshal(rec)
+	if err != nil {
+		i.log.Error("idempotency marshal record", zap.Error(err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyWriteTimeout)
+	defer cancel()
+	err = i.cache.Set(ctx, storeKey, string(recBytes), i.cfg.TTL)
+	if err != nil {
+		i.log.Error("idempotency set", zap.Error(err))
+	}
+}
+
+// fingerprint hashes a request's deterministic wire encoding.
+func fingerprint(msg proto.Message) (string, error) {
+	reqBytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(reqBytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// retryable reports whether a code may succeed if the request is retried.
+func retryable(code codes.Code) bool {
+	switch code {
+	case codes.Unknown,
+		codes.Canceled,
+		codes.DeadlineExceeded,
+		codes.ResourceExhausted,
+		codes.Aborted,
+		codes.Internal,
+		codes.Unavailable:
+		return true
+	}
+	return false
+}
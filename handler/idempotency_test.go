@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"fx-sample-app/gateway/redis"
+)
+
+const testMethod = "/fxsample.fxsample/CreatePerson"
+
+// idempotentCall runs handler through the interceptor with key.
+func idempotentCall(
+	ctx context.Context,
+	i *idempotency,
+	key string,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(idempotencyHeader, key))
+	return i.unary(ctx, wrapperspb.String("req"), &grpc.UnaryServerInfo{FullMethod: testMethod}, handler)
+}
+
+func TestIdempotencyStoresAfterCancel(t *testing.T) {
+	cache := redis.NewMemory(0)
+	i := newIdempotency(cache, idempotencyConfig{
+		TTL:     time.Minute,
+		LockTTL: time.Second,
+		Methods: []string{testMethod},
+	}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := idempotentCall(ctx, i, "k", func(context.Context, interface{}) (interface{}, error) {
+		// The client goes away once the change is made.
+		cancel()
+		return wrapperspb.String("created"), nil
+	})
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	calls := 0
+	resp, err := idempotentCall(context.Background(), i, "k", func(context.Context, interface{}) (interface{}, error) {
+		calls++
+		return wrapperspb.String("again"), nil
+	})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("retry ran the handler %d times, want replay", calls)
+	}
+	if got := resp.(*wrapperspb.StringValue).GetValue(); got != "created" {
+		t.Fatalf("replayed %q, want created", got)
+	}
+
+	// The lock was released despite the canceled context.
+	lease, err := cache.Acquire(context.Background(), "idempotency:"+testMethod+":ip::k", time.Second)
+	if err != nil {
+		t.Fatalf("lock still held: %v", err)
+	}
+	_ = cache.Release(context.Background(), lease)
+}
+
+func TestIdempotencyRenewsLock(t *testing.T) {
+	lockTTL := 60 * time.Millisecond
+	i := newIdempotency(redis.NewMemory(0), idempotencyConfig{
+		TTL:     time.Minute,
+		LockTTL: lockTTL,
+		Methods: []string{testMethod},
+	}, zap.NewNop())
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := idempotentCall(context.Background(), i, "slow", func(context.Context, interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			return wrapperspb.String("created"), nil
+		})
+		done <- err
+	}()
+	<-started
+
+	// Well past the lock ttl, duplicates are still turned away.
+	time.Sleep(4 * lockTTL)
+	_, err := idempotentCall(context.Background(), i, "slow", func(context.Context, interface{}) (interface{}, error) {
+		return nil, errors.New("duplicate ran")
+	})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("duplicate during request = %v, want Aborted", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+}
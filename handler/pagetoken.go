@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+// paginationConfig defines settings under the pagination key.
+type paginationConfig struct {
+	// DefaultPageSize applies when a request sets no page size.
+	DefaultPageSize int `yaml:"default_page_size"`
+	// MaxPageSize caps larger page sizes.
+	MaxPageSize int `yaml:"max_page_size"`
+	// TokenKey signs page tokens so clients cannot forge cursors.
+	TokenKey string `yaml:"token_key"`
+	// Env is the running environment, only dev and test may sign
+	// with the placeholder key.
+	Env string `yaml:"env"`
+}
+
+// placeholderTokenKey is the token_key default, known to anyone.
+const placeholderTokenKey = "placeholder"
+
+// placeholderEnvs are the environments allowed the placeholder key.
+var placeholderEnvs = []string{"dev", "test"}
+
+// pageToken is the signed content of a page token. Query ties the
+// token to the listing and order it was issued for.
+type pageToken struct {
+	Query string      `json:"q"`
+	Key   interface{} `json:"k"`
+	ID    string      `json:"id"`
+}
+
+// pager turns AIP-158 list fields into gateway pages and back.
+type pager struct {
+	cfg paginationConfig
+	key []byte
+}
+
+// newPager is the pager constructor.
+func newPager(cfg paginationConfig) (*pager, error) {
+	if cfg.TokenKey == "" {
+		return nil, errors.New("token_key is required")
+	}
+	if cfg.TokenKey == placeholderTokenKey && !slices.Contains(placeholderEnvs, cfg.Env) {
+		return nil, fmt.Errorf("token_key must be set outside %s, env is %q",
+			strings.Join(placeholderEnvs, " and "), cfg.Env)
+	}
+	if cfg.DefaultPageSize < 1 || cfg.MaxPageSize < cfg.DefaultPageSize {
+		return nil, fmt.Errorf(
+			"page sizes must satisfy 0 < default (%d) <= max (%d)",
+			cfg.DefaultPageSize,
+			cfg.MaxPageSize,
+		)
+	}
+	return &pager{
+		cfg: cfg,
+		key: []byte(cfg.TokenKey),
+	}, nil
+}
+
+// page builds the page a list request of resource asks for. Only
+// sortable fields may be ordered by.
+func (p *pager) page(
+	resource string,
+	sortable []string,
+	size int32,
+	token string,
+	orderBy string,
+) (postgres.Page, error) {
+	var page postgres.Page
+
+	fields := strings.Fields(orderBy)
+	switch {
+	case len(fields) == 2 && strings.EqualFold(fields[1], "desc"):
+		page.Desc = true
+	case len(fields) == 2 && strings.EqualFold(fields[1], "asc"):
+	case len(fields) > 1:
+		return postgres.Page{}, invalidf("order_by must be \"field\" or \"field desc\"")
+	}
+	if len(fields) > 0 {
+		if !fieldSet(sortable)[fields[0]] {
+			return postgres.Page{}, invalidf(
+				"cannot order by %q, expected one of %s",
+				fields[0],
+				strings.Join(sortable, ", "),
+			)
+		}
+		page.OrderBy = fields[0]
+	}
+
+	switch {
+	case size < 0:
+		return postgres.Page{}, invalidf("page_size must not be negative")
+	case size == 0:
+		page.Size = p.cfg.DefaultPageSize
+	default:
+		page.Size = min(int(size), p.cfg.MaxPageSize)
+	}
+
+	if token != "" {
+		cursor, err := p.decode(tokenQuery(resource, page), token)
+		if err != nil {
+			return postgres.Page{}, invalidf("invalid page_token: %v", err)
+		}
+		page.After = &cursor
+	}
+
+	return page, nil
+}
+
+// next returns the token of the page after row, the last of page.
+func (p *pager) next(resource string, page postgres.Page, row interface{}) (string, error) {
+	cursor, err := postgres.CursorFor(row, page.OrderBy)
+	if err != nil {
+		return "", fmt.Errorf("CursorFor %w", err)
+	}
+
+	payload, err := json.Marshal(pageToken{
+		Query: tokenQuery(resource, page),
+		Key:   cursor.Key,
+		ID:    cursor.ID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal %w", err)
+	}
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(p.sign(payload)), nil
+}
+
+// decode verifies token and returns its cursor when issued for q.
+func (p *pager) decode(q string, token string) (postgres.Cursor, error) {
+	enc := base64.RawURLEncoding
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return postgres.Cursor{}, errors.New("malformed")
+	}
+	payload, err := enc.DecodeString(encPayload)
+	if err != nil {
+		return postgres.Cursor{}, errors.New("malformed")
+	}
+	sig, err := enc.DecodeString(encSig)
+	if err != nil || !hmac.Equal(sig, p.sign(payload)) {
+		return postgres.Cursor{}, errors.New("bad signature")
+	}
+
+	// Numbers stay json.Number so large keys keep their precision.
+	var tok pageToken
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	err = dec.Decode(&tok)
+	if err != nil {
+		return postgres.Cursor{}, errors.New("malformed")
+	}
+	if tok.Query != q {
+		return postgres.Cursor{}, errors.New("issued for a different query")
+	}
+
+	return postgres.Cursor{
+		Key: tok.Key,
+		ID:  tok.ID,
+	}, nil
+}
+
+// sign returns the HMAC-SHA256 of payload.
+func (p *pager) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// tokenQuery identifies a listing of resource in the order of page.
+func tokenQuery(resource string, page postgres.Page) string {
+	dir := "asc"
+	if page.Desc {
+		dir = "desc"
+	}
+	return resource + ":" + page.OrderBy + ":" + dir
+}
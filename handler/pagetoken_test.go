@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+func TestNewPagerTokenKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		env  string
+		ok   bool
+	}{
+		{"secret", "s3cret", "prod", true},
+		{"placeholder dev", "placeholder", "dev", true},
+		{"placeholder test", "placeholder", "test", true},
+		{"placeholder prod", "placeholder", "prod", false},
+		{"placeholder no env", "placeholder", "", false},
+		{"placeholder production", "placeholder", "production", false},
+		{"empty", "", "dev", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPager(paginationConfig{
+				DefaultPageSize: 50,
+				MaxPageSize:     1000,
+				TokenKey:        tt.key,
+				Env:             tt.env,
+			})
+			if (err == nil) != tt.ok {
+				t.Fatalf("newPager: got %v, want ok %t", err, tt.ok)
+			}
+		})
+	}
+}
+
+// testPager returns a pager signing with key.
+func testPager(t *testing.T, key string) *pager {
+	t.Helper()
+	p, err := newPager(paginationConfig{
+		DefaultPageSize: 50,
+		MaxPageSize:     1000,
+		TokenKey:        key,
+		Env:             "test",
+	})
+	if err != nil {
+		t.Fatalf("newPager: %v", err)
+	}
+	return p
+}
+
+// nextToken returns the token after row in people ordered by orderBy.
+func nextToken(t *testing.T, p *pager, orderBy string, row postgres.Person) string {
+	t.Helper()
+	page, err := p.page("people", peopleSortable, 10, "", orderBy)
+	if err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	token, err := p.next("people", page, row)
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	return token
+}
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	p := testPager(t, "s3cret")
+	// Beyond float64 precision, the key must survive unrounded.
+	row := postgres.Person{ID: "b", Timestamp: 1<<53 + 1}
+	token := nextToken(t, p, "timestamp desc", row)
+
+	page, err := p.page("people", peopleSortable, 10, token, "timestamp desc")
+	if err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	if page.After == nil {
+		t.Fatal("page has no cursor")
+	}
+	want := postgres.Cursor{Key: json.Number("9007199254740993"), ID: "b"}
+	if *page.After != want {
+		t.Fatalf("cursor %+v, want %+v", *page.After, want)
+	}
+	if page.OrderBy != "timestamp" || !page.Desc {
+		t.Fatalf("page %+v, want timestamp desc", page)
+	}
+}
+
+func TestPageTokenRejected(t *testing.T) {
+	p := testPager(t, "s3cret")
+	token := nextToken(t, p, "timestamp", postgres.Person{ID: "b", Timestamp: 7})
+	payload, sig, _ := strings.Cut(token, ".")
+
+	// The same cursor pointing elsewhere, under the original signature.
+	forged := nextToken(t, p, "timestamp", postgres.Person{ID: "z", Timestamp: 7})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+
+	tests := []struct {
+		name     string
+		resource string
+		orderBy  string
+		token    string
+	}{
+		{"tampered payload", "people", "timestamp", forgedPayload + "." + sig},
+		{"tampered signature", "people", "timestamp", payload + "." + sig[1:]},
+		{"other key", "people", "timestamp", nextToken(t, testPager(t, "other"), "timestamp", postgres.Person{ID: "b"})},
+		{"malformed", "people", "timestamp", "not-a-token"},
+		{"other resource", "places", "timestamp", token},
+		{"other order", "people", "timestamp desc", token},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.page(tt.resource, []string{"timestamp"}, 10, tt.token, tt.orderBy)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("page: got %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "fx-sample-app/proto/fxsample"
+	"fx-sample-app/records"
+)
+
+// peopleSortable are the fields a listing may be ordered by.
+var peopleSortable = []string{"id", "first_name", "last_name", "email", "timestamp"}
+
+// CreatePerson stores a new person.
+func (h *Handlers) CreatePerson(
+	ctx context.Context,
+	req *pb.CreatePersonRequest,
+) (*pb.Person, error) {
+	if req.Person == nil {
+		return nil, invalidf("person is required")
+	}
+	err := invalid(records.ValidateID(req.Person.Id))
+	if err != nil {
+		return nil, err
+	}
+	err = invalid(records.ValidatePerson(req.Person, records.PersonFields))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := h.con.CreatePerson(ctx, records.PersonFromPB(req.Person))
+	if err != nil {
+		return nil, dbStatus(ctx, err, "create person")
+	}
+
+	return records.PersonToPB(p), nil
+}
+
+// GetPerson returns a person by id.
+func (h *Handlers) GetPerson(
+	ctx context.Context,
+	req *pb.GetPersonRequest,
+) (*pb.Person, error) {
+	err := requireID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = showDeleted(ctx, req.ShowDeleted)
+	p, err := h.con.GetPerson(ctx, req.Id)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "get person")
+	}
+
+	return records.PersonToPB(p), nil
+}
+
+// UpdatePerson sets the masked fields of a person.
+func (h *Handlers) UpdatePerson(
+	ctx context.Context,
+	req *pb.UpdatePersonRequest,
+) (*pb.Person, error) {
+	if req.Person == nil {
+		return nil, invalidf("person is required")
+	}
+	err := requireID(req.Person.Id)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := maskColumns(req.UpdateMask, req.Person, records.PersonFields)
+	if err != nil {
+		return nil, err
+	}
+	err = invalid(records.ValidatePerson(req.Person, columns))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = precondition(ctx, req.Person.Version)
+	if err != nil {
+		return nil, err
+	}
+	p, err := h.con.UpdatePerson(ctx, records.PersonFromPB(req.Person), columns)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "update person")
+	}
+
+	return records.PersonToPB(p), nil
+}
+
+// DeletePerson removes a person by id.
+func (h *Handlers) DeletePerson(
+	ctx context.Context,
+	req *pb.DeletePersonRequest,
+) (*emptypb.Empty, error) {
+	err := requireID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = precondition(ctx, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	err = h.con.DeletePerson(ctx, req.Id)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "delete person")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ListPeople returns a page of people.
+func (h *Handlers) ListPeople(
+	ctx context.Context,
+	req *pb.ListPeopleRequest,
+) (*pb.ListPeopleResponse, error) {
+	page, err := h.pages.page("people", peopleSortable, req.PageSize, req.PageToken, req.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = showDeleted(ctx, req.ShowDeleted)
+	people, more, err := h.con.ListPeople(ctx, page)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "list people")
+	}
+
+	resp := &pb.ListPeopleResponse{}
+	for _, p := range people {
+		resp.People = append(resp.People, records.PersonToPB(p))
+	}
+	if more {
+		resp.NextPageToken, err = h.pages.next("people", page, people[len(people)-1])
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list people: %v", err)
+		}
+	}
+
+	// The total is best effort, a failed estimate leaves it unset.
+	total, err := h.con.EstimatePeople(ctx)
+	if err != nil {
+		h.log.Warn("estimate people", zap.Error(err))
+	} else {
+		resp.TotalSize = total
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+
+	"fx-sample-app/controller"
+	"fx-sample-app/gateway/postgres"
+	pb "fx-sample-app/proto/fxsample"
+)
+
+// storingController returns people as the gateway stores them.
+type storingController struct {
+	controller.Controller
+}
+
+func (storingController) CreatePerson(ctx context.Context, p postgres.Person) (postgres.Person, error) {
+	p.CreatedAt = 100
+	p.UpdatedAt = 100
+	p.Version = 1
+	return p, nil
+}
+
+func TestCreatePersonReturnsVersionAndETag(t *testing.T) {
+	h := &Handlers{con: storingController{}}
+	mux := runtime.NewServeMux(runtime.WithForwardResponseOption(setETag))
+	err := pb.RegisterFxsampleHandlerServer(context.Background(), mux, h)
+	if err != nil {
+		t.Fatalf("RegisterFxsampleHandlerServer: %v", err)
+	}
+
+	body := `{"id":"0b5c9f8e-1a2b-4c3d-8e9f-0a1b2c3d4e5f","first_name":"Ada","last_name":"Lovelace","email":"ada@example.com"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/people", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: got %d %s", w.Code, w.Body)
+	}
+
+	var created struct {
+		ID        string `json:"id"`
+		Version   string `json:"version"`
+		CreatedAt string `json:"createdAt"`
+	}
+	err = json.Unmarshal(w.Body.Bytes(), &created)
+	if err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	if created.ID == "" || created.Version != "1" || created.CreatedAt != "100" {
+		t.Fatalf("created %s, want version 1 created at 100", w.Body)
+	}
+	if got := w.Header().Get("ETag"); got != `"1"` {
+		t.Fatalf("ETag: got %q, want %q", got, `"1"`)
+	}
+}
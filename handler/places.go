@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	pb "fx-sample-app/proto/fxsample"
+	"fx-sample-app/records"
+)
+
+// placesSortable are the fields a listing may be ordered by.
+var placesSortable = []string{"id", "country", "telcode", "timestamp"}
+
+// CreatePlace stores a new place.
+func (h *Handlers) CreatePlace(
+	ctx context.Context,
+	req *pb.CreatePlaceRequest,
+) (*pb.Place, error) {
+	if req.Place == nil {
+		return nil, invalidf("place is required")
+	}
+	err := invalid(records.ValidateID(req.Place.Id))
+	if err != nil {
+		return nil, err
+	}
+	err = invalid(records.ValidatePlace(req.Place, records.NewPlaceFields))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := h.con.CreatePlace(ctx, records.PlaceFromPB(req.Place))
+	if err != nil {
+		return nil, dbStatus(ctx, err, "create place")
+	}
+
+	return records.PlaceToPB(p), nil
+}
+
+// GetPlace returns a place by id.
+func (h *Handlers) GetPlace(
+	ctx context.Context,
+	req *pb.GetPlaceRequest,
+) (*pb.Place, error) {
+	err := requireID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = showDeleted(ctx, req.ShowDeleted)
+	p, err := h.con.GetPlace(ctx, req.Id)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "get place")
+	}
+
+	return records.PlaceToPB(p), nil
+}
+
+// UpdatePlace sets the masked fields of a place.
+func (h *Handlers) UpdatePlace(
+	ctx context.Context,
+	req *pb.UpdatePlaceRequest,
+) (*pb.Place, error) {
+	if req.Place == nil {
+		return nil, invalidf("place is required")
+	}
+	err := requireID(req.Place.Id)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := maskColumns(req.UpdateMask, req.Place, records.PlaceFields)
+	if err != nil {
+		return nil, err
+	}
+	err = invalid(records.ValidatePlace(req.Place, columns))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = precondition(ctx, req.Place.Version)
+	if err != nil {
+		return nil, err
+	}
+	p, err := h.con.UpdatePlace(ctx, records.PlaceFromPB(req.Place), columns)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "update place")
+	}
+
+	return records.PlaceToPB(p), nil
+}
+
+// DeletePlace removes a place by id.
+func (h *Handlers) DeletePlace(
+	ctx context.Context,
+	req *pb.DeletePlaceRequest,
+) (*emptypb.Empty, error) {
+	err := requireID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = precondition(ctx, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	err = h.con.DeletePlace(ctx, req.Id)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "delete place")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// AddPlaceComment adds a comment to a place.
+func (h *Handlers) AddPlaceComment(
+	ctx context.Context,
+	req *pb.AddPlaceCommentRequest,
+) (*pb.Comment, error) {
+	err := requireID(req.PlaceId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Comment == nil {
+		return nil, invalidf("comment is required")
+	}
+	if req.Comment.Id != "" || req.Comment.CreatedAt != 0 {
+		return nil, invalidf("comment id and created_at are assigned by the server")
+	}
+	err = invalid(records.ValidateComment(req.Comment))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = precondition(ctx, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	c, err := h.con.AddPlaceComment(ctx, req.PlaceId, records.CommentFromPB(req.Comment))
+	if err != nil {
+		return nil, dbStatus(ctx, err, "add place comment")
+	}
+
+	return records.CommentToPB(c), nil
+}
+
+// RemovePlaceComment removes a comment from a place.
+func (h *Handlers) RemovePlaceComment(
+	ctx context.Context,
+	req *pb.RemovePlaceCommentRequest,
+) (*emptypb.Empty, error) {
+	err := requireID(req.PlaceId)
+	if err != nil {
+		return nil, err
+	}
+	err = requireID(req.CommentId)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = precondition(ctx, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	err = h.con.RemovePlaceComment(ctx, req.PlaceId, req.CommentId)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "remove place comment")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ListPlaces returns a page of places.
+func (h *Handlers) ListPlaces(
+	ctx context.Context,
+	req *pb.ListPlacesRequest,
+) (*pb.ListPlacesResponse, error) {
+	page, err := h.pages.page("places", placesSortable, req.PageSize, req.PageToken, req.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = showDeleted(ctx, req.ShowDeleted)
+	places, more, err := h.con.ListPlaces(ctx, page)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "list places")
+	}
+
+	resp := &pb.ListPlacesResponse{}
+	for _, p := range places {
+		resp.Places = append(resp.Places, records.PlaceToPB(p))
+	}
+	if more {
+		resp.NextPageToken, err = h.pages.next("places", page, places[len(places)-1])
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list places: %v", err)
+		}
+	}
+
+	// The total is best effort, a failed estimate leaves it unset.
+	total, err := h.con.EstimatePlaces(ctx)
+	if err != nil {
+		h.log.Warn("estimate places", zap.Error(err))
+	} else {
+		resp.TotalSize = total
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+// readPrimaryHeader set to true makes a call read from the primary, so
+// it sees the caller's earlier writes even while replicas lag.
+const readPrimaryHeader = "x-read-primary"
+
+// readPrimary returns ctx reading from the primary when the call asks to.
+func readPrimary(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(readPrimaryHeader)
+	if len(values) == 0 {
+		return ctx
+	}
+	primary, err := strconv.ParseBool(values[0])
+	if err != nil || !primary {
+		return ctx
+	}
+	return postgres.WithPrimary(ctx)
+}
+
+// primaryUnary routes reads of calls asking for it to the primary.
+func primaryUnary(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	return handler(readPrimary(ctx), req)
+}
+
+// primaryStream routes reads of streaming calls asking for it to the primary.
+func primaryStream(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx := readPrimary(ss.Context())
+	return handler(srv, actorServerStream{ServerStream: ss, ctx: ctx})
+}
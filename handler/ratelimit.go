@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"fx-sample-app/gateway/redis"
+)
+
+// apiKeyHeader identifies API callers on both HTTP and gRPC.
+const apiKeyHeader = "x-api-key"
+
+// proxyHeader carries proxySecret on calls from the REST proxy.
+const proxyHeader = "x-fxsample-proxy"
+
+// proxySecret marks calls made by this process's REST proxy. It is
+// random per process, so other callers of the gRPC port cannot set it.
+var proxySecret = newProxySecret()
+
+// newProxySecret returns a random hex secret.
+func newProxySecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("proxy secret: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// proxyUnary is the REST proxy's client interceptor marking its calls.
+func proxyUnary(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	return invoker(markProxied(ctx), method, req, reply, cc, opts...)
+}
+
+// proxyStream is the streaming counterpart of proxyUnary.
+func proxyStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	return streamer(markProxied(ctx), desc, cc, method, opts...)
+}
+
+// markProxied sets proxySecret on ctx's outgoing metadata, replacing
+// any value forwarded from the HTTP request.
+func markProxied(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(proxyHeader, proxySecret)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// proxied reports whether md came from this process's REST proxy.
+func proxied(md metadata.MD) bool {
+	vals := md.Get(proxyHeader)
+	return len(vals) == 1 && subtle.ConstantTimeCompare([]byte(vals[0]), []byte(proxySecret)) == 1
+}
+
+// rateLimitConfig defines per route limits under the ratelimit key.
+// Routes are gRPC full method names or HTTP paths.
+type rateLimitConfig struct {
+	Enabled bool                   `yaml:"enabled"`
+	Default redis.Limit            `yaml:"default"`
+	Routes  map[string]redis.Limit `yaml:"routes"`
+}
+
+// rateLimiter applies token bucket limits keyed by route and caller.
+type rateLimiter struct {
+	cache redis.Gateway
+	cfg   rateLimitConfig
+	log   *zap.Logger
+}
+
+// limitFor returns the route's limit, false when the route is unlimited.
+func (l *rateLimiter) limitFor(route string) (redis.Limit, bool) {
+	if !l.cfg.Enabled {
+		return redis.Limit{}, false
+	}
+	limit, ok := l.cfg.Routes[route]
+	if !ok {
+		limit = l.cfg.Default
+	}
+	return limit, limit.Events > 0
+}
+
+// allow reports whether caller may call route. Limiter errors fail open.
+func (l *rateLimiter) allow(ctx context.Context, route, caller string) redis.Result {
+	limit, ok := l.limitFor(route)
+	if !ok {
+		return redis.Result{Allowed: true}
+	}
+
+	res, err := l.cache.Allow(ctx, route+":"+caller, limit)
+	if err != nil {
+		l.log.Error("rate limit", zap.String("route", route), zap.Error(err))
+		return redis.Result{Allowed: true}
+	}
+	if !res.Allowed {
+		l.log.Info("rate limited",
+			zap.String("route", route),
+			zap.String("caller", caller),
+			zap.Duration("retry_after", res.RetryAfter),
+		)
+	}
+
+	return res
+}
+
+// unary is the gRPC interceptor, also covering REST calls through the proxy.
+func (l *rateLimiter) unary(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	res := l.allow(ctx, info.FullMethod, grpcCaller(ctx))
+	if !res.Allowed {
+		retryAfter := retryAfterSeconds(res.RetryAfter)
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfter))
+		return nil, status.Errorf(
+			codes.ResourceExhausted,
+			"rate limit exceeded, retry after %ss",
+			retryAfter,
+		)
+	}
+
+	return handler(ctx, req)
+}
+
+// allowHTTP reports whether caller may call a plain HTTP route such as
+// the slack slash command, answering 429 when not. Callers must be
+// verified first so they cannot pick their own bucket.
+func (l *rateLimiter) allowHTTP(w http.ResponseWriter, r *http.Request, route, caller string) bool {
+	res := l.allow(r.Context(), route, caller)
+	if !res.Allowed {
+		w.Header().Set("Retry-After", retryAfterSeconds(res.RetryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// grpcCaller identifies a gRPC caller by its verified API key, else
+// client IP. x-forwarded-for is only trusted on calls marked by the
+// REST proxy, other callers are identified by their peer address.
+func grpcCaller(ctx context.Context) string {
+	if caller, ok := authCaller(ctx); ok {
+		return "key:" + caller
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if proxied(md) {
+		if fwd := md.Get("x-forwarded-for"); len(fwd) > 0 {
+			return "ip:" + forwardedClient(fwd[len(fwd)-1])
+		}
+	}
+
+	var host string
+	if p, ok := peer.FromContext(ctx); ok {
+		host = hostOf(p.Addr.String())
+	}
+
+	return "ip:" + host
+}
+
+// forwardedClient returns the address the REST proxy appended to
+// x-forwarded-for, the rightmost entry. Earlier entries are sent by
+// the client and may be anything.
+func forwardedClient(fwd string) string {
+	entries := strings.Split(fwd, ",")
+	return strings.TrimSpace(entries[len(entries)-1])
+}
+
+// hostOf strips the port from an address.
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// retryAfterSeconds formats a wait as whole seconds, rounding up.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(d.Seconds()))))
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"fx-sample-app/gateway/redis"
+	"fx-sample-app/jobs"
+)
+
+func TestGRPCCaller(t *testing.T) {
+	// proxiedMD is the metadata a call through the REST proxy carries.
+	proxiedMD := func(pairs ...string) metadata.MD {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(pairs...))
+		md, _ := metadata.FromOutgoingContext(markProxied(ctx))
+		return md
+	}
+
+	tests := []struct {
+		name string
+		peer string
+		md   metadata.MD
+		want string
+	}{
+		{"peer", "203.0.113.9:4000", nil, "ip:203.0.113.9"},
+		{"proxied", "127.0.0.1:4000", proxiedMD("x-forwarded-for", "198.51.100.7"), "ip:198.51.100.7"},
+		{"proxied spoofed forwarded", "127.0.0.1:4000", proxiedMD("x-forwarded-for", "10.9.9.9, 198.51.100.7"), "ip:198.51.100.7"},
+		{"proxied forwarded metadata", "127.0.0.1:4000", proxiedMD(
+			"x-forwarded-for", "10.9.9.9",
+			"x-forwarded-for", "198.51.100.7",
+		), "ip:198.51.100.7"},
+		{"proxied without forwarded", "127.0.0.1:4000", proxiedMD(), "ip:127.0.0.1"},
+		{"loopback unmarked", "127.0.0.1:4000", metadata.Pairs("x-forwarded-for", "198.51.100.7"), "ip:127.0.0.1"},
+		{"remote unmarked", "203.0.113.9:4000", metadata.Pairs("x-forwarded-for", "198.51.100.7"), "ip:203.0.113.9"},
+		{"forged marker", "203.0.113.9:4000", metadata.Pairs(
+			proxyHeader, "guess",
+			"x-forwarded-for", "198.51.100.7",
+		), "ip:203.0.113.9"},
+		{"forwarded marker replaced", "127.0.0.1:4000", proxiedMD(
+			proxyHeader, "guess",
+			"x-forwarded-for", "198.51.100.7",
+		), "ip:198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := net.ResolveTCPAddr("tcp", tt.peer)
+			if err != nil {
+				t.Fatalf("ResolveTCPAddr: %v", err)
+			}
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+			ctx = metadata.NewIncomingContext(ctx, tt.md)
+			if got := grpcCaller(ctx); got != tt.want {
+				t.Fatalf("grpcCaller: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// countingQueue records enqueued jobs.
+type countingQueue struct {
+	jobs.Queue
+	enqueued int
+}
+
+func (q *countingQueue) Enqueue(context.Context, string, any) (string, error) {
+	q.enqueued++
+	return "job", nil
+}
+
+func TestSlackLimitedAfterSignature(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_KEY", "signing-key")
+	queue := &countingQueue{}
+	h := &Handlers{
+		jobs: queue,
+		limiter: &rateLimiter{
+			cache: redis.NewMemory(0),
+			cfg: rateLimitConfig{
+				Enabled: true,
+				Routes: map[string]redis.Limit{
+					slackCommandsPath: {Events: 1, Period: time.Minute, Burst: 1},
+				},
+			},
+			log: zap.NewNop(),
+		},
+	}
+
+	command := func(userID string, signed bool) int {
+		body := url.Values{"command": {"/cat_fact"}, "user_id": {userID}}.Encode()
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		sig := "v0=forged"
+		if signed {
+			mac := hmac.New(sha256.New, []byte("signing-key"))
+			mac.Write([]byte("v0:" + timestamp + ":" + body))
+			sig = "v0=" + string(mac.Sum(nil))
+		}
+		r := httptest.NewRequest(http.MethodPost, slackCommandsPath, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		r.Header.Set("X-Slack-Signature", sig)
+		w := httptest.NewRecorder()
+		h.catsAAS(w, r)
+		return w.Code
+	}
+
+	// Forged commands are refused without using the user's bucket.
+	for i := 0; i < 3; i++ {
+		if code := command("U1", false); code != http.StatusBadRequest {
+			t.Fatalf("forged command: got %d, want 400", code)
+		}
+	}
+	if code := command("U1", true); code != http.StatusOK {
+		t.Fatalf("first command: got %d, want 200", code)
+	}
+	if code := command("U1", true); code != http.StatusTooManyRequests {
+		t.Fatalf("second command: got %d, want 429", code)
+	}
+	if code := command("U2", true); code != http.StatusOK {
+		t.Fatalf("other user: got %d, want 200", code)
+	}
+	if queue.enqueued != 2 {
+		t.Fatalf("enqueued %d follow ups, want 2", queue.enqueued)
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+// dbStatus maps postgres gateway errors to gRPC status codes.
+// Statements stopped because the caller went away report the caller's
+// context error, others stopped by the query timeout DeadlineExceeded.
+func dbStatus(ctx context.Context, err error, action string) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return status.FromContextError(ctxErr).Err()
+	}
+
+	switch {
+	case postgres.IsCanceled(err):
+		return status.Errorf(codes.DeadlineExceeded, "%s: %v", action, err)
+	case errors.Is(err, postgres.ErrNotFound):
+		return status.Errorf(codes.NotFound, "%s: %v", action, err)
+	case errors.Is(err, postgres.ErrAlreadyExists):
+		return status.Errorf(codes.AlreadyExists, "%s: %v", action, err)
+	case errors.Is(err, postgres.ErrInvalidFilter):
+		return status.Errorf(codes.InvalidArgument, "%s: %v", action, err)
+	case errors.Is(err, postgres.ErrLimitExceeded):
+		return status.Errorf(codes.FailedPrecondition, "%s: %v", action, err)
+	case errors.Is(err, postgres.ErrVersionMismatch):
+		return status.Errorf(codes.Aborted, "%s: %v", action, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", action, err)
+}
+
+// maskColumns resolves an update mask to columns. An empty mask selects
+// the populated fields of msg, "*" selects every updatable field.
+func maskColumns(
+	mask *fieldmaskpb.FieldMask,
+	msg proto.Message,
+	updatable []string,
+) ([]string, error) {
+	paths := mask.GetPaths()
+	if len(paths) == 1 && paths[0] == "*" {
+		return updatable, nil
+	}
+
+	allowed := make(map[string]bool, len(updatable))
+	for _, name := range updatable {
+		allowed[name] = true
+	}
+
+	if len(paths) == 0 {
+		fields := msg.ProtoReflect().Descriptor().Fields()
+		for _, name := range updatable {
+			fd := fields.ByName(protoreflect.Name(name))
+			if fd != nil && msg.ProtoReflect().Has(fd) {
+				paths = append(paths, name)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no fields to update")
+	}
+
+	seen := make(map[string]bool, len(paths))
+	var columns []string
+	for _, path := range paths {
+		if !allowed[path] {
+			return nil, status.Errorf(codes.InvalidArgument, "field %q cannot be updated", path)
+		}
+		if !seen[path] {
+			seen[path] = true
+			columns = append(columns, path)
+		}
+	}
+
+	return columns, nil
+}
+
+// fieldSet returns fields as a set.
+func fieldSet(fields []string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		set[field] = true
+	}
+	return set
+}
+
+// requireID rejects an empty resource id.
+func requireID(id string) error {
+	if id == "" {
+		return status.Error(codes.InvalidArgument, "id is required")
+	}
+	return nil
+}
+
+// invalid wraps a validation error as an InvalidArgument status.
+func invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// invalidf builds an InvalidArgument status.
+func invalidf(format string, args ...interface{}) error {
+	return status.Errorf(codes.InvalidArgument, format, args...)
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+func TestDBStatus(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	statementCanceled := &pq.Error{Code: "57014", Message: "canceling statement due to user request"}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want codes.Code
+	}{
+		{"caller canceled", canceled, statementCanceled, codes.Canceled},
+		{"caller deadline", expired, statementCanceled, codes.DeadlineExceeded},
+		{"query timeout", context.Background(), fmt.Errorf("GetContext %w", statementCanceled), codes.DeadlineExceeded},
+		{"query timeout context", context.Background(), fmt.Errorf("GetContext %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{"not found", context.Background(), fmt.Errorf("person x %w", postgres.ErrNotFound), codes.NotFound},
+		{"exists", context.Background(), postgres.ErrAlreadyExists, codes.AlreadyExists},
+		{"invalid filter", context.Background(), postgres.ErrInvalidFilter, codes.InvalidArgument},
+		{"limit", context.Background(), postgres.ErrLimitExceeded, codes.FailedPrecondition},
+		{"version", context.Background(), postgres.ErrVersionMismatch, codes.Aborted},
+		{"other", context.Background(), fmt.Errorf("boom"), codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dbStatus(tt.ctx, tt.err, "get person")
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("dbStatus: got %s (%v), want %s", got, err, tt.want)
+			}
+		})
+	}
+}
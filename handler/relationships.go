@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"fx-sample-app/gateway/postgres"
+	pb "fx-sample-app/proto/fxsample"
+	"fx-sample-app/records"
+)
+
+// relationshipsSortable are the fields a relationship listing may be
+// ordered by.
+var relationshipsSortable = []string{"id", "start_date"}
+
+// CreateRelationship stores a new relationship between a person and a place.
+func (h *Handlers) CreateRelationship(
+	ctx context.Context,
+	req *pb.CreateRelationshipRequest,
+) (*pb.Relationship, error) {
+	if req.Relationship == nil {
+		return nil, invalidf("relationship is required")
+	}
+	err := invalid(records.ValidateRelationship(req.Relationship))
+	if err != nil {
+		return nil, err
+	}
+	row, err := records.RelationshipFromPB(req.Relationship)
+	if err != nil {
+		return nil, invalid(err)
+	}
+
+	r, err := h.con.CreateRelationship(ctx, row)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "create relationship")
+	}
+
+	return records.RelationshipToPB(r), nil
+}
+
+// DeleteRelationship removes a relationship by id.
+func (h *Handlers) DeleteRelationship(
+	ctx context.Context,
+	req *pb.DeleteRelationshipRequest,
+) (*emptypb.Empty, error) {
+	err := requireID(req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err = precondition(ctx, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	err = h.con.DeleteRelationship(ctx, req.Id)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "delete relationship")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ListRelationships returns a page of the relationships of a person,
+// a place or both.
+func (h *Handlers) ListRelationships(
+	ctx context.Context,
+	req *pb.ListRelationshipsRequest,
+) (*pb.ListRelationshipsResponse, error) {
+	if req.PersonId == "" && req.PlaceId == "" {
+		return nil, invalidf("person_id or place_id is required")
+	}
+	q, resource, err := relationshipQuery("relationships", req.PersonId, req.PlaceId, req.Role, req.ActiveOn)
+	if err != nil {
+		return nil, err
+	}
+	page, err := h.pages.page(resource, relationshipsSortable, req.PageSize, req.PageToken, req.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = showDeleted(ctx, req.ShowDeleted)
+	relationships, more, err := h.con.ListRelationships(ctx, q, page)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "list relationships")
+	}
+
+	resp := &pb.ListRelationshipsResponse{}
+	for _, r := range relationships {
+		resp.Relationships = append(resp.Relationships, records.RelationshipToPB(r))
+	}
+	if more {
+		resp.NextPageToken, err = h.pages.next(resource, page, relationships[len(relationships)-1])
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list relationships: %v", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// ListPersonPlaces returns a page of the places a person has a
+// matching relationship with.
+func (h *Handlers) ListPersonPlaces(
+	ctx context.Context,
+	req *pb.ListPersonPlacesRequest,
+) (*pb.ListPlacesResponse, error) {
+	err := requireID(req.PersonId)
+	if err != nil {
+		return nil, err
+	}
+	q, resource, err := relationshipQuery("person-places", req.PersonId, "", req.Role, req.ActiveOn)
+	if err != nil {
+		return nil, err
+	}
+	page, err := h.pages.page(resource, placesSortable, req.PageSize, req.PageToken, req.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	places, more, err := h.con.ListRelatedPlaces(ctx, q, page)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "list person places")
+	}
+
+	resp := &pb.ListPlacesResponse{}
+	for _, p := range places {
+		resp.Places = append(resp.Places, records.PlaceToPB(p))
+	}
+	if more {
+		resp.NextPageToken, err = h.pages.next(resource, page, places[len(places)-1])
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list person places: %v", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// ListPlacePeople returns a page of the people with a matching
+// relationship with a place.
+func (h *Handlers) ListPlacePeople(
+	ctx context.Context,
+	req *pb.ListPlacePeopleRequest,
+) (*pb.ListPeopleResponse, error) {
+	err := requireID(req.PlaceId)
+	if err != nil {
+		return nil, err
+	}
+	q, resource, err := relationshipQuery("place-people", "", req.PlaceId, req.Role, req.ActiveOn)
+	if err != nil {
+		return nil, err
+	}
+	page, err := h.pages.page(resource, peopleSortable, req.PageSize, req.PageToken, req.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	people, more, err := h.con.ListRelatedPeople(ctx, q, page)
+	if err != nil {
+		return nil, dbStatus(ctx, err, "list place people")
+	}
+
+	resp := &pb.ListPeopleResponse{}
+	for _, p := range people {
+		resp.People = append(resp.People, records.PersonToPB(p))
+	}
+	if more {
+		resp.NextPageToken, err = h.pages.next(resource, page, people[len(people)-1])
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "list place people: %v", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// relationshipQuery validates relationship list fields. The returned
+// resource names the listing, so page tokens only resume the same one.
+func relationshipQuery(
+	listing string,
+	personID string,
+	placeID string,
+	role pb.Role,
+	activeOn string,
+) (postgres.RelationshipQuery, string, error) {
+	q := postgres.RelationshipQuery{
+		PersonID: personID,
+		PlaceID:  placeID,
+		Role:     records.RoleFromPB(role),
+	}
+	for _, id := range []string{personID, placeID} {
+		err := invalid(records.ValidateID(id))
+		if err != nil {
+			return postgres.RelationshipQuery{}, "", err
+		}
+	}
+	if activeOn != "" {
+		day, err := records.ParseDate("active_on", activeOn)
+		if err != nil {
+			return postgres.RelationshipQuery{}, "", invalid(err)
+		}
+		q.ActiveOn = day
+	}
+
+	params := url.Values{}
+	params.Set("person_id", personID)
+	params.Set("place_id", placeID)
+	params.Set("role", string(q.Role))
+	params.Set("active_on", activeOn)
+	return q, listing + "?" + params.Encode(), nil
+}
@@ -0,0 +1,318 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"fx-sample-app/gateway/redis"
+)
+
+// dequeueBlock bounds how long a worker waits for new jobs per poll.
+const dequeueBlock = 2 * time.Second
+
+// Handler processes jobs of one type.
+type Handler interface {
+	// Type is the job type routed to this handler.
+	Type() string
+	// Handle runs one attempt. Returning an error retries with backoff.
+	Handle(ctx context.Context, job redis.Job) error
+}
+
+// AsHandler annotates a Handler constructor to join the jobs group.
+func AsHandler(f any) any {
+	return fx.Annotate(
+		f,
+		fx.As(new(Handler)),
+		fx.ResultTags(`group:"jobs"`),
+	)
+}
+
+// Queue enqueues and administers jobs.
+type Queue interface {
+	// Enqueue schedules a job with payload encoded as JSON, returning its JobID.
+	Enqueue(ctx context.Context, jobType string, payload any) (string, error)
+	// DeadLetters lists up to limit jobs that exhausted their attempts.
+	DeadLetters(ctx context.Context, limit int) ([]redis.Job, error)
+	// Replay requeues a dead lettered job by its entry id.
+	Replay(ctx context.Context, id string) (redis.Job, error)
+}
+
+// Config defines worker settings under the jobs key.
+type Config struct {
+	Queue       string        `yaml:"queue"`
+	Workers     int           `yaml:"workers"`
+	MaxAttempts int           `yaml:"max_attempts"`
+	Backoff     time.Duration `yaml:"backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+	Timeout     time.Duration `yaml:"timeout"`
+	// ReclaimIdle is how long a delivery may go unacknowledged
+	// before another consumer takes it over.
+	ReclaimIdle time.Duration `yaml:"reclaim_idle"`
+}
+
+// Params defines constructor requirements.
+type Params struct {
+	fx.In
+
+	Cache    redis.Gateway
+	Cfg      config.Provider
+	Log      *zap.Logger
+	Lc       fx.Lifecycle
+	Handlers []Handler `group:"jobs"`
+}
+
+type queue struct {
+	cache    redis.Gateway
+	cfg      Config
+	log      *zap.Logger
+	handlers map[string]Handler
+	consumer string
+}
+
+// New is the Queue constructor. Workers run for the life of the app.
+func New(p Params) (Queue, error) {
+	cfg := Config{
+		Queue:       "default",
+		Workers:     1,
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		MaxBackoff:  5 * time.Minute,
+		Timeout:     30 * time.Second,
+		ReclaimIdle: 2 * time.Minute,
+	}
+	err := p.Cfg.Get("jobs").Populate(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("jobs config %w", err)
+	}
+	if cfg.ReclaimIdle <= cfg.Timeout {
+		return nil, fmt.Errorf("jobs reclaim_idle must exceed timeout")
+	}
+
+	handlers := make(map[string]Handler, len(p.Handlers))
+	for _, h := range p.Handlers {
+		if _, ok := handlers[h.Type()]; ok {
+			return nil, fmt.Errorf("duplicate job handler %q", h.Type())
+		}
+		handlers[h.Type()] = h
+	}
+
+	hostname, _ := os.Hostname()
+	q := &queue{
+		cache:    p.Cache,
+		cfg:      cfg,
+		log:      p.Log.With(zap.String("queue", cfg.Queue)),
+		handlers: handlers,
+		consumer: fmt.Sprintf("%s-%s", hostname, uuid.New().String()[:8]),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	p.Lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			for i := 0; i < cfg.Workers; i++ {
+				wg.Add(1)
+				go func(consumer string) {
+					defer wg.Done()
+					q.work(ctx, consumer)
+				}(fmt.Sprintf("%s-%d", q.consumer, i))
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				q.reclaim(ctx, q.consumer+"-reclaim")
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			// In flight jobs finish, unfinished ones are reclaimed later.
+			cancel()
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+				q.log.Warn("jobs still running at shutdown")
+			}
+			return nil
+		},
+	})
+
+	return q, nil
+}
+
+// Enqueue schedules a job with payload encoded as JSON, returning its JobID.
+func (q *queue) Enqueue(ctx context.Context, jobType string, payload any) (string, error) {
+	if _, ok := q.handlers[jobType]; !ok {
+		return "", fmt.Errorf("unknown job type %q", jobType)
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("marshal payload %w", err)
+	}
+
+	return q.cache.Enqueue(ctx, q.cfg.Queue, redis.Job{
+		Type:    jobType,
+		Payload: payloadBytes,
+	})
+}
+
+// DeadLetters lists up to limit jobs that exhausted their attempts.
+func (q *queue) DeadLetters(ctx context.Context, limit int) ([]redis.Job, error) {
+	return q.cache.DeadLetters(ctx, q.cfg.Queue, limit)
+}
+
+// Replay requeues a dead lettered job by its entry id.
+func (q *queue) Replay(ctx context.Context, id string) (redis.Job, error) {
+	job, err := q.cache.Replay(ctx, q.cfg.Queue, id)
+	if err != nil {
+		return redis.Job{}, err
+	}
+
+	q.log.Info("replayed dead letter",
+		zap.String("id", id),
+		zap.String("job_id", job.JobID),
+	)
+	return job, nil
+}
+
+// work processes new jobs until ctx is canceled.
+func (q *queue) work(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		jobs, err := q.cache.Dequeue(ctx, q.cfg.Queue, consumer, 1, dequeueBlock)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			q.log.Error("dequeue", zap.Error(err))
+			sleep(ctx, time.Second)
+			continue
+		}
+
+		for _, job := range jobs {
+			q.process(job)
+		}
+	}
+}
+
+// reclaim takes over jobs abandoned by crashed consumers until ctx is canceled.
+func (q *queue) reclaim(ctx context.Context, consumer string) {
+	ticker := time.NewTicker(q.cfg.ReclaimIdle / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		jobs, err := q.cache.Reclaim(ctx, q.cfg.Queue, consumer, q.cfg.ReclaimIdle, 10)
+		if err != nil {
+			if ctx.Err() == nil {
+				q.log.Error("reclaim", zap.Error(err))
+			}
+			continue
+		}
+
+		for _, job := range jobs {
+			q.log.Warn("reclaimed abandoned job",
+				zap.String("job_id", job.JobID),
+				zap.String("type", job.Type),
+			)
+			q.process(job)
+		}
+	}
+}
+
+// process runs one delivery and acks, retries or dead letters it.
+// Jobs run detached from shutdown so in flight work can finish.
+func (q *queue) process(job redis.Job) {
+	ctx := context.Background()
+	log := q.log.With(
+		zap.String("job_id", job.JobID),
+		zap.String("type", job.Type),
+		zap.Int("attempt", job.Attempt),
+	)
+
+	h, ok := q.handlers[job.Type]
+	if !ok {
+		if job.LastError == "" {
+			job.LastError = fmt.Sprintf("no handler for job type %q", job.Type)
+		}
+		q.deadLetter(ctx, log, job)
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, q.cfg.Timeout)
+	err := h.Handle(jobCtx, job)
+	cancel()
+	if err == nil {
+		if err := q.cache.Ack(ctx, q.cfg.Queue, job); err != nil {
+			log.Error("ack job", zap.Error(err))
+		}
+		return
+	}
+
+	job.Attempt++
+	job.LastError = err.Error()
+	if job.Attempt >= q.cfg.MaxAttempts {
+		q.deadLetter(ctx, log, job)
+		return
+	}
+
+	delay := q.backoff(job.Attempt)
+	log.Warn("job failed, retrying",
+		zap.Duration("delay", delay),
+		zap.Error(err),
+	)
+	if err := q.cache.Retry(ctx, q.cfg.Queue, job, delay); err != nil {
+		log.Error("retry job", zap.Error(err))
+	}
+}
+
+// deadLetter parks a job that cannot succeed.
+func (q *queue) deadLetter(ctx context.Context, log *zap.Logger, job redis.Job) {
+	log.Error("job dead lettered", zap.String("error", job.LastError))
+	if err := q.cache.DeadLetter(ctx, q.cfg.Queue, job); err != nil {
+		log.Error("dead letter job", zap.Error(err))
+	}
+}
+
+// backoff doubles the delay per attempt up to MaxBackoff, with jitter
+// so retries of jobs that failed together spread out.
+func (q *queue) backoff(attempt int) time.Duration {
+	delay := q.cfg.Backoff
+	for i := 1; i < attempt && delay < q.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, q.cfg.MaxBackoff)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// sleep waits for d or until ctx is canceled.
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -1,17 +1,29 @@
 package main
 
 import (
+	"os"
+
 	"fx-sample-app/app"
+	"fx-sample-app/cli"
 	"fx-sample-app/controller"
 	"fx-sample-app/handler"
+	"fx-sample-app/jobs"
+	"fx-sample-app/outbox"
 
 	"go.uber.org/fx"
 )
 
 func main() {
+	// Run one off commands such as migrate instead of the server.
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	fx.New(
 		app.Module,        // provide gateways.
 		controller.Module, // provide controller interface.
+		jobs.Module,       // run job workers.
+		outbox.Module,     // relay entity change events.
 		handler.Module,    // wire up to handlers.
 	).Run()
 }
@@ -0,0 +1,13 @@
+package outbox
+
+import "go.uber.org/fx"
+
+var Module = fx.Module(
+	"outbox",
+	fx.Provide(
+		AsPublisher(NewRedisPublisher),
+		AsPublisher(NewWebhookPublisher),
+		AsPublisher(NewSlackPublisher),
+	),
+	fx.Invoke(Run),
+)
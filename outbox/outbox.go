@@ -0,0 +1,295 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"go.uber.org/config"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+// purgeInterval is how often delivered events past retention are removed.
+const purgeInterval = 10 * time.Minute
+
+// Event is the message published for an entity change.
+type Event struct {
+	// ID is unique per change, consumers dedupe redeliveries by it.
+	ID int64 `json:"id"`
+	// Topic is the entity and operation, such as person.update.
+	Topic     string `json:"topic"`
+	Entity    string `json:"entity"`
+	EntityID  string `json:"entity_id"`
+	Operation string `json:"operation"`
+	Actor     string `json:"actor"`
+	// At is in unix seconds.
+	At int64 `json:"at"`
+	// Data is the row after the change, before it when deleted.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Publisher delivers events to one kind of consumer.
+type Publisher interface {
+	// Name identifies the publisher in logs.
+	Name() string
+	// Publish delivers one event. Returning an error retries the event
+	// with this publisher only, so delivery is at least once.
+	Publish(ctx context.Context, e Event) error
+}
+
+// AsPublisher annotates a Publisher constructor to join the outbox group.
+func AsPublisher(f any) any {
+	return fx.Annotate(
+		f,
+		fx.As(new(Publisher)),
+		fx.ResultTags(`group:"outbox"`),
+	)
+}
+
+// Config defines relay and publisher settings under the outbox key.
+type Config struct {
+	Enabled      bool          `yaml:"enabled"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
+	// Timeout bounds publishing a batch, and PublishTimeout one event
+	// with one publisher.
+	Timeout        time.Duration `yaml:"timeout"`
+	PublishTimeout time.Duration `yaml:"publish_timeout"`
+	Backoff        time.Duration `yaml:"backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	// MaxAttempts is how often an event is published before it is
+	// marked failed, so it stops holding back its entity's events.
+	MaxAttempts int `yaml:"max_attempts"`
+	// Retention is how long delivered events are kept.
+	Retention time.Duration `yaml:"retention"`
+	// RedisPrefix starts the pub/sub channel of each topic.
+	RedisPrefix string `yaml:"redis_prefix"`
+	// Webhooks are posted each event, signed with WebhookSecret when set.
+	Webhooks      []string `yaml:"webhooks"`
+	WebhookSecret string   `yaml:"webhook_secret"`
+	// SlackWebhook is an incoming webhook notified of each event.
+	SlackWebhook string `yaml:"slack_webhook"`
+}
+
+// loadConfig reads the outbox settings over their defaults.
+func loadConfig(p config.Provider) (Config, error) {
+	cfg := Config{
+		PollInterval:   time.Second,
+		BatchSize:      100,
+		Timeout:        30 * time.Second,
+		PublishTimeout: 5 * time.Second,
+		Backoff:        time.Second,
+		MaxBackoff:     5 * time.Minute,
+		MaxAttempts:    10,
+		Retention:      24 * time.Hour,
+		RedisPrefix:    "events:",
+	}
+	err := p.Get("outbox").Populate(&cfg)
+	if err != nil {
+		return Config{}, fmt.Errorf("outbox config %w", err)
+	}
+	if cfg.PollInterval <= 0 || cfg.BatchSize <= 0 || cfg.Timeout <= 0 ||
+		cfg.PublishTimeout <= 0 || cfg.MaxAttempts <= 0 {
+		return Config{}, fmt.Errorf(
+			"outbox poll_interval, batch_size, timeout, publish_timeout and max_attempts must be positive",
+		)
+	}
+	return cfg, nil
+}
+
+// Params defines Run requirements.
+type Params struct {
+	fx.In
+
+	DB         postgres.Gateway
+	Cfg        config.Provider
+	Log        *zap.Logger
+	Lc         fx.Lifecycle
+	Publishers []Publisher `group:"outbox"`
+}
+
+type relay struct {
+	db         postgres.Gateway
+	cfg        Config
+	log        *zap.Logger
+	publishers []Publisher
+}
+
+// Run relays outbox events to the publishers for the life of the app.
+// Every replica relays, claimed events are skipped by the others.
+func Run(p Params) error {
+	cfg, err := loadConfig(p.Cfg)
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	r := &relay{
+		db:         p.DB,
+		cfg:        cfg,
+		log:        p.Log.Named("outbox"),
+		publishers: p.Publishers,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.Lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				r.run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			// The batch in flight finishes, later events wait for a relay.
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+				r.log.Warn("outbox relay still running at shutdown")
+			}
+			return nil
+		},
+	})
+
+	return nil
+}
+
+// run relays batches until ctx is canceled, polling while idle.
+func (r *relay) run(ctx context.Context) {
+	var purged time.Time
+	for ctx.Err() == nil {
+		claimed, err := r.relayBatch()
+		if err != nil {
+			r.log.Error("relay outbox", zap.Error(err))
+		}
+		// A full batch suggests more are due.
+		if err == nil && claimed == r.cfg.BatchSize {
+			continue
+		}
+
+		if time.Since(purged) >= purgeInterval {
+			r.purge()
+			purged = time.Now()
+		}
+		sleep(ctx, r.cfg.PollInterval)
+	}
+}
+
+// relayBatch publishes one batch of due events. It runs detached from
+// shutdown so claimed events are not left to their lease.
+func (r *relay) relayBatch() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.Timeout)
+	defer cancel()
+
+	return r.db.RelayOutbox(ctx, postgres.OutboxRelay{
+		Limit: r.cfg.BatchSize,
+		// Events left unpublished when the batch times out are due
+		// again once it would have ended.
+		Lease:       r.cfg.Timeout,
+		MaxAttempts: r.cfg.MaxAttempts,
+		Publish: func(oe postgres.OutboxEvent) ([]string, error) {
+			return r.publish(ctx, oe)
+		},
+		RetryAfter: r.backoff,
+	})
+}
+
+// publish delivers oe to the publishers it has not been delivered to,
+// returning those it was delivered to now.
+func (r *relay) publish(ctx context.Context, oe postgres.OutboxEvent) ([]string, error) {
+	e := Event{
+		ID:        oe.ID,
+		Topic:     oe.Topic,
+		Entity:    oe.Entity,
+		EntityID:  oe.EntityID,
+		Operation: string(oe.Operation),
+		Actor:     oe.Actor,
+		At:        oe.CreatedAt,
+		Data:      json.RawMessage(oe.Payload),
+	}
+
+	var published []string
+	var errs []error
+	for _, p := range r.publishers {
+		if slices.Contains(oe.Published, p.Name()) {
+			continue
+		}
+		pctx, cancel := context.WithTimeout(ctx, r.cfg.PublishTimeout)
+		err := p.Publish(pctx, e)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %w", p.Name(), err))
+			continue
+		}
+		published = append(published, p.Name())
+	}
+
+	err := errors.Join(errs...)
+	if err != nil && oe.Attempts+1 >= r.cfg.MaxAttempts {
+		r.log.Error("publish event failed, giving up",
+			zap.Int64("id", e.ID),
+			zap.String("topic", e.Topic),
+			zap.Int("attempt", oe.Attempts+1),
+			zap.Error(err),
+		)
+	} else if err != nil {
+		r.log.Warn("publish event failed, retrying",
+			zap.Int64("id", e.ID),
+			zap.String("topic", e.Topic),
+			zap.Int("attempt", oe.Attempts+1),
+			zap.Error(err),
+		)
+	}
+	return published, err
+}
+
+// purge removes events delivered before the retention period.
+func (r *relay) purge() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.Timeout)
+	defer cancel()
+
+	before := time.Now().Add(-r.cfg.Retention).UTC().Unix()
+	n, err := r.db.PurgeOutbox(ctx, before)
+	if err != nil {
+		r.log.Error("purge outbox", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		r.log.Info("purged delivered events", zap.Int64("count", n))
+	}
+}
+
+// backoff doubles the delay per attempt up to MaxBackoff, with jitter
+// so events that failed together spread out.
+func (r *relay) backoff(attempt int) time.Duration {
+	delay := r.cfg.Backoff
+	for i := 1; i < attempt && delay < r.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, r.cfg.MaxBackoff)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// sleep waits for d or until ctx is canceled.
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,96 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"fx-sample-app/gateway/postgres"
+)
+
+// fakePublisher records events and fails or hangs when set to.
+type fakePublisher struct {
+	name  string
+	err   error
+	hang  bool
+	calls int
+}
+
+func (f *fakePublisher) Name() string {
+	return f.name
+}
+
+func (f *fakePublisher) Publish(ctx context.Context, e Event) error {
+	f.calls++
+	if f.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.err
+}
+
+func newTestRelay(publishers ...Publisher) *relay {
+	return &relay{
+		cfg: Config{
+			PublishTimeout: 50 * time.Millisecond,
+			MaxAttempts:    3,
+		},
+		log:        zap.NewNop(),
+		publishers: publishers,
+	}
+}
+
+func TestPublishSkipsDeliveredPublishers(t *testing.T) {
+	redis := &fakePublisher{name: "redis"}
+	webhook := &fakePublisher{name: "webhook", err: errors.New("503")}
+	slack := &fakePublisher{name: "slack"}
+	r := newTestRelay(redis, webhook, slack)
+
+	published, err := r.publish(context.Background(), postgres.OutboxEvent{
+		ID:        1,
+		Published: []string{"redis"},
+	})
+	if err == nil {
+		t.Fatal("publish: got nil error with a failing publisher")
+	}
+	if !slices.Equal(published, []string{"slack"}) {
+		t.Fatalf("published: got %v, want [slack]", published)
+	}
+	if redis.calls != 0 || webhook.calls != 1 || slack.calls != 1 {
+		t.Fatalf("calls: redis %d, webhook %d, slack %d", redis.calls, webhook.calls, slack.calls)
+	}
+}
+
+func TestPublishTimesOutHangingPublisher(t *testing.T) {
+	hanging := &fakePublisher{name: "webhook", hang: true}
+	slack := &fakePublisher{name: "slack"}
+	r := newTestRelay(hanging, slack)
+
+	start := time.Now()
+	published, err := r.publish(context.Background(), postgres.OutboxEvent{ID: 1})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("publish took %s, want the 50ms publish timeout", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("publish: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !slices.Equal(published, []string{"slack"}) {
+		t.Fatalf("published: got %v, want [slack]", published)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := newTestRelay()
+	r.cfg.Backoff = time.Second
+	r.cfg.MaxBackoff = 10 * time.Second
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 10 * time.Second} {
+		got := r.backoff(attempt)
+		if got < want/2 || got > want {
+			t.Errorf("backoff(%d): got %s, want within [%s, %s]", attempt, got, want/2, want)
+		}
+	}
+}
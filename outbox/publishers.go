@@ -0,0 +1,170 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/config"
+	"go.uber.org/fx"
+
+	"fx-sample-app/gateway/redis"
+	"fx-sample-app/gateway/slack"
+)
+
+// PublisherParams defines publisher constructor requirements.
+type PublisherParams struct {
+	fx.In
+
+	Cfg   config.Provider
+	Cache redis.Gateway
+	Slack slack.Gateway
+}
+
+// redisPublisher publishes events on a pub/sub channel per topic.
+type redisPublisher struct {
+	cache  redis.Gateway
+	prefix string
+}
+
+// NewRedisPublisher is the redis pub/sub Publisher constructor.
+func NewRedisPublisher(p PublisherParams) (Publisher, error) {
+	cfg, err := loadConfig(p.Cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &redisPublisher{
+		cache:  p.Cache,
+		prefix: cfg.RedisPrefix,
+	}, nil
+}
+
+// Name .
+func (r *redisPublisher) Name() string {
+	return "redis"
+}
+
+// Publish sends e as JSON on the channel of its topic.
+func (r *redisPublisher) Publish(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("json.Marshal %w", err)
+	}
+	return r.cache.Publish(ctx, r.prefix+e.Topic, string(payload))
+}
+
+// webhookPublisher posts events to HTTP endpoints.
+type webhookPublisher struct {
+	client *http.Client
+	urls   []string
+	secret []byte
+}
+
+// NewWebhookPublisher is the webhook Publisher constructor.
+func NewWebhookPublisher(p PublisherParams) (Publisher, error) {
+	cfg, err := loadConfig(p.Cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookPublisher{
+		client: &http.Client{Timeout: cfg.PublishTimeout},
+		urls:   cfg.Webhooks,
+		secret: []byte(cfg.WebhookSecret),
+	}, nil
+}
+
+// Name .
+func (w *webhookPublisher) Name() string {
+	return "webhook"
+}
+
+// Publish posts e as JSON to every webhook. The body is signed with an
+// X-Signature HMAC-SHA256 when a secret is set.
+func (w *webhookPublisher) Publish(ctx context.Context, e Event) error {
+	if len(w.urls) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("json.Marshal %w", err)
+	}
+
+	for _, url := range w.urls {
+		err := w.post(ctx, url, e, body)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// post sends one webhook request, failing on a non 2xx status.
+func (w *webhookPublisher) post(ctx context.Context, url string, e Event, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("NewRequest %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", strconv.FormatInt(e.ID, 10))
+	req.Header.Set("X-Event-Topic", e.Topic)
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("post %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// slackPublisher notifies a slack channel of events.
+type slackPublisher struct {
+	slack      slack.Gateway
+	webhookURL string
+}
+
+// NewSlackPublisher is the slack Publisher constructor.
+func NewSlackPublisher(p PublisherParams) (Publisher, error) {
+	cfg, err := loadConfig(p.Cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &slackPublisher{
+		slack:      p.Slack,
+		webhookURL: cfg.SlackWebhook,
+	}, nil
+}
+
+// Name .
+func (s *slackPublisher) Name() string {
+	return "slack"
+}
+
+// Publish posts a line about e when a webhook is set.
+func (s *slackPublisher) Publish(ctx context.Context, e Event) error {
+	if s.webhookURL == "" {
+		return nil
+	}
+	return s.slack.Notify(ctx, s.webhookURL, fmt.Sprintf(
+		"%s %s by %s",
+		e.Topic,
+		e.EntityID,
+		e.Actor,
+	))
+}
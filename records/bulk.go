@@ -0,0 +1,242 @@
+package records
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"fx-sample-app/gateway/postgres"
+	pb "fx-sample-app/proto/fxsample"
+)
+
+// maxImportErrors bounds the invalid rows an ImportError lists.
+const maxImportErrors = 100
+
+// ImportError rejects an import with invalid rows.
+type ImportError struct {
+	// Rows are the first invalid rows.
+	Rows []*LineError
+	// Count includes invalid rows past those listed.
+	Count int
+}
+
+// Error implements error, listing a row per line.
+func (e *ImportError) Error() string {
+	var b strings.Builder
+	if e.Count == 1 {
+		b.WriteString("1 invalid row")
+	} else {
+		fmt.Fprintf(&b, "%d invalid rows", e.Count)
+	}
+	for _, row := range e.Rows {
+		b.WriteString("\n")
+		b.WriteString(row.Error())
+	}
+	if e.Count > len(e.Rows) {
+		fmt.Fprintf(&b, "\nand %d more", e.Count-len(e.Rows))
+	}
+	return b.String()
+}
+
+// add records an invalid row.
+func (e *ImportError) add(row *LineError) {
+	e.Count++
+	if len(e.Rows) < maxImportErrors {
+		e.Rows = append(e.Rows, row)
+	}
+}
+
+// ImportPeople validates people read from r and stores them in one
+// transaction. Ids and timestamps are assigned as on create, while
+// creation and deletion times are kept so exports load back as they
+// were. Any invalid row rejects the import with an *ImportError.
+func ImportPeople(
+	ctx context.Context,
+	db postgres.Gateway,
+	r io.Reader,
+	format Format,
+	upsert bool,
+) (int64, error) {
+	p := &pb.Person{}
+	next, err := scan(r, format, p, func() error {
+		err := ValidateID(p.Id)
+		if err != nil {
+			return err
+		}
+		return ValidatePerson(p, PersonFields)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC().Unix()
+	return db.ImportPeople(ctx, func() (postgres.Person, error) {
+		err := next()
+		if err != nil {
+			return postgres.Person{}, err
+		}
+		row := PersonFromPB(p)
+		row.CreatedAt = p.CreatedAt
+		row.DeletedAt = deletedAt(p.DeletedAt)
+		if row.ID == "" {
+			row.ID = uuid.New().String()
+		}
+		if row.Timestamp == 0 {
+			row.Timestamp = now
+		}
+		return row, nil
+	}, upsert)
+}
+
+// ExportPeople writes every person to w, deleted ones only when ctx
+// comes from postgres.WithDeleted.
+func ExportPeople(ctx context.Context, db postgres.Gateway, w io.Writer, format Format) error {
+	enc, err := newEncoder(w, format, (&pb.Person{}).ProtoReflect().Descriptor())
+	if err != nil {
+		return err
+	}
+
+	err = db.ExportPeople(ctx, func(p postgres.Person) error {
+		return enc.encode(PersonToPB(p))
+	})
+	if err != nil {
+		return err
+	}
+
+	return enc.flush()
+}
+
+// ImportPlaces validates places read from r and stores them, see
+// ImportPeople.
+func ImportPlaces(
+	ctx context.Context,
+	db postgres.Gateway,
+	r io.Reader,
+	format Format,
+	upsert bool,
+) (int64, error) {
+	p := &pb.Place{}
+	next, err := scan(r, format, p, func() error {
+		err := ValidateID(p.Id)
+		if err != nil {
+			return err
+		}
+		return ValidatePlace(p, NewPlaceFields)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC().Unix()
+	return db.ImportPlaces(ctx, func() (postgres.Place, error) {
+		err := next()
+		if err != nil {
+			return postgres.Place{}, err
+		}
+		row := PlaceFromPB(p)
+		row.CreatedAt = p.CreatedAt
+		row.DeletedAt = deletedAt(p.DeletedAt)
+		if row.ID == "" {
+			row.ID = uuid.New().String()
+		}
+		if row.Timestamp == 0 {
+			row.Timestamp = now
+		}
+		StampComments(row.Comments, now)
+		return row, nil
+	}, upsert)
+}
+
+// ExportPlaces writes every place to w, see ExportPeople.
+func ExportPlaces(ctx context.Context, db postgres.Gateway, w io.Writer, format Format) error {
+	enc, err := newEncoder(w, format, (&pb.Place{}).ProtoReflect().Descriptor())
+	if err != nil {
+		return err
+	}
+
+	err = db.ExportPlaces(ctx, func(p postgres.Place) error {
+		return enc.encode(PlaceToPB(p))
+	})
+	if err != nil {
+		return err
+	}
+
+	return enc.flush()
+}
+
+// scan returns a function decoding the next valid row of r into msg.
+// It returns io.EOF at the end, or the *ImportError when rows were
+// invalid. Rows repeating an earlier row's id are invalid, since one
+// statement cannot write a row twice. Rows after the first invalid
+// one are checked but not returned, since the import is rejected
+// anyway.
+func scan(
+	r io.Reader,
+	format Format,
+	msg proto.Message,
+	validate func() error,
+) (func() error, error) {
+	dec, err := newDecoder(r, format, msg.ProtoReflect().Descriptor())
+	var lineErr *LineError
+	if errors.As(err, &lineErr) {
+		return nil, &ImportError{Rows: []*LineError{lineErr}, Count: 1}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines are kept by id to report where a duplicate first appeared.
+	idField := msg.ProtoReflect().Descriptor().Fields().ByName("id")
+	seen := make(map[string]int)
+
+	invalid := &ImportError{}
+	return func() error {
+		for {
+			proto.Reset(msg)
+			err := dec.next(msg)
+			switch {
+			case errors.As(err, &lineErr):
+				invalid.add(lineErr)
+				continue
+			case errors.Is(err, io.EOF) && invalid.Count > 0:
+				return invalid
+			case err != nil:
+				return err
+			}
+
+			err = validate()
+			if err != nil {
+				invalid.add(&LineError{Line: dec.line, Err: err})
+				continue
+			}
+			if id := rowID(msg, idField); id != "" {
+				if first, ok := seen[id]; ok {
+					invalid.add(&LineError{
+						Line: dec.line,
+						Err:  fmt.Errorf("duplicate id %q, first on line %d", id, first),
+					})
+					continue
+				}
+				seen[id] = dec.line
+			}
+			if invalid.Count == 0 {
+				return nil
+			}
+		}
+	}, nil
+}
+
+// rowID returns the id of msg, empty when unset or msg has no id.
+func rowID(msg proto.Message, idField protoreflect.FieldDescriptor) string {
+	if idField == nil {
+		return ""
+	}
+	return msg.ProtoReflect().Get(idField).String()
+}
@@ -0,0 +1,67 @@
+package records
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	pb "fx-sample-app/proto/fxsample"
+)
+
+func TestScanRejectsDuplicateIDs(t *testing.T) {
+	input := strings.Join([]string{
+		`{"id":"a","first_name":"Ada"}`,
+		`{"id":"b","first_name":"Grace"}`,
+		`{"first_name":"Alan"}`,
+		`{"first_name":"Edsger"}`,
+		`{"id":"a","first_name":"Ada"}`,
+	}, "\n")
+
+	p := &pb.Person{}
+	next, err := scan(strings.NewReader(input), NDJSON, p, func() error { return nil })
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	for {
+		err = next()
+		if err != nil {
+			break
+		}
+	}
+	var importErr *ImportError
+	if !errors.As(err, &importErr) {
+		t.Fatalf("next: got %v, want *ImportError", err)
+	}
+	if importErr.Count != 1 || importErr.Rows[0].Line != 5 {
+		t.Fatalf("invalid rows: got %v, want line 5 only", importErr)
+	}
+	if want := `duplicate id "a", first on line 1`; importErr.Rows[0].Err.Error() != want {
+		t.Fatalf("row error: got %q, want %q", importErr.Rows[0].Err, want)
+	}
+}
+
+func TestScanAllowsDistinctIDs(t *testing.T) {
+	input := `{"id":"a"}` + "\n" + `{"id":"b"}` + "\n"
+
+	p := &pb.Person{}
+	next, err := scan(strings.NewReader(input), NDJSON, p, func() error { return nil })
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	rows := 0
+	for {
+		err = next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		rows++
+	}
+	if rows != 2 {
+		t.Fatalf("rows: got %d, want 2", rows)
+	}
+}
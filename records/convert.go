@@ -0,0 +1,136 @@
+package records
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"fx-sample-app/gateway/postgres"
+	pb "fx-sample-app/proto/fxsample"
+)
+
+// PersonFromPB converts an API person to a row. Change times and the
+// version are set by the gateway and left out.
+func PersonFromPB(p *pb.Person) postgres.Person {
+	return postgres.Person{
+		ID:        p.Id,
+		FirstName: p.FirstName,
+		LastName:  p.LastName,
+		Email:     p.Email,
+		Timestamp: p.Timestamp,
+	}
+}
+
+// PersonToPB converts a row to an API person.
+func PersonToPB(p postgres.Person) *pb.Person {
+	person := &pb.Person{
+		Id:        p.ID,
+		FirstName: p.FirstName,
+		LastName:  p.LastName,
+		Email:     p.Email,
+		Timestamp: p.Timestamp,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		Version:   p.Version,
+	}
+	if p.DeletedAt.Valid {
+		person.DeletedAt = &p.DeletedAt.Int64
+	}
+	return person
+}
+
+// PlaceFromPB converts an API place to a row, see PersonFromPB.
+func PlaceFromPB(p *pb.Place) postgres.Place {
+	return postgres.Place{
+		ID:      p.Id,
+		Country: p.Country,
+		City: sql.NullString{
+			String: p.GetCity(),
+			Valid:  p.City != nil,
+		},
+		Comments:  CommentsFromPB(p.Comments),
+		TelCode:   int(p.Telcode),
+		Timestamp: p.Timestamp,
+	}
+}
+
+// PlaceToPB converts a row to an API place.
+func PlaceToPB(p postgres.Place) *pb.Place {
+	place := &pb.Place{
+		Id:        p.ID,
+		Country:   p.Country,
+		Comments:  CommentsToPB(p.Comments),
+		Telcode:   int32(p.TelCode),
+		Timestamp: p.Timestamp,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		Version:   p.Version,
+	}
+	if p.DeletedAt.Valid {
+		place.DeletedAt = &p.DeletedAt.Int64
+	}
+	if p.City.Valid {
+		place.City = &p.City.String
+	}
+	return place
+}
+
+// CommentFromPB converts an API comment to its stored form.
+func CommentFromPB(c *pb.Comment) postgres.Comment {
+	return postgres.Comment{
+		ID:        c.Id,
+		Author:    c.Author,
+		Text:      c.Text,
+		Fields:    c.Fields,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+// CommentToPB converts a stored comment to an API comment.
+func CommentToPB(c postgres.Comment) *pb.Comment {
+	return &pb.Comment{
+		Id:        c.ID,
+		Author:    c.Author,
+		Text:      c.Text,
+		Fields:    c.Fields,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+// CommentsFromPB converts API comments, never returning nil.
+func CommentsFromPB(comments []*pb.Comment) postgres.Comments {
+	out := make(postgres.Comments, len(comments))
+	for i, c := range comments {
+		out[i] = CommentFromPB(c)
+	}
+	return out
+}
+
+// CommentsToPB converts stored comments.
+func CommentsToPB(comments postgres.Comments) []*pb.Comment {
+	var out []*pb.Comment
+	for _, c := range comments {
+		out = append(out, CommentToPB(c))
+	}
+	return out
+}
+
+// deletedAt converts an optional API deletion time.
+func deletedAt(t *int64) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *t, Valid: true}
+}
+
+// StampComments assigns ids and creation times to new comments.
+func StampComments(comments postgres.Comments, now int64) {
+	for i := range comments {
+		if comments[i].ID == "" {
+			comments[i].ID = uuid.New().String()
+		}
+		if comments[i].CreatedAt == 0 {
+			comments[i].CreatedAt = now
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package records
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxLineSize bounds a single NDJSON line.
+const maxLineSize = 1 << 20
+
+// Format is a bulk file format.
+type Format int
+
+const (
+	// CSV has a header row of field names. Repeated fields are JSON
+	// arrays, of objects for messages, and empty cells leave fields unset.
+	CSV Format = iota
+	// NDJSON has one JSON object per line, as in the REST API.
+	NDJSON
+)
+
+// ParseFormat parses a format name, csv or ndjson.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "csv":
+		return CSV, nil
+	case "ndjson", "jsonl":
+		return NDJSON, nil
+	}
+	return 0, fmt.Errorf("unknown format %q, expected csv or ndjson", name)
+}
+
+// FormatOf infers the format of a file from its extension.
+func FormatOf(path string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// String returns the format name.
+func (f Format) String() string {
+	if f == NDJSON {
+		return "ndjson"
+	}
+	return "csv"
+}
+
+// LineError is an invalid row and the line it starts on.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+// Error implements error.
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the row error.
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// decoder reads one message per CSV record or NDJSON line.
+type decoder struct {
+	format Format
+	csv    *csv.Reader
+	lines  *bufio.Reader
+	// line is where the last row read starts.
+	line int
+	// header maps CSV columns to fields.
+	header []protoreflect.FieldDescriptor
+}
+
+// newDecoder reads from r, and for CSV the header row naming fields of
+// desc. An invalid header is returned as *LineError.
+func newDecoder(r io.Reader, format Format, desc protoreflect.MessageDescriptor) (*decoder, error) {
+	d := &decoder{format: format}
+	if format == NDJSON {
+		d.lines = bufio.NewReader(r)
+		return d, nil
+	}
+
+	d.csv = csv.NewReader(r)
+	d.csv.FieldsPerRecord = -1
+	d.csv.ReuseRecord = true
+	names, err := d.csv.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, &LineError{Line: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err == io.EOF {
+		return nil, &LineError{Line: 1, Err: errors.New("missing csv header")}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv header %w", err)
+	}
+	d.line, _ = d.csv.FieldPos(0)
+
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		fd := desc.Fields().ByName(protoreflect.Name(name))
+		if fd == nil || !csvKind(fd) {
+			return nil, &LineError{Line: d.line, Err: fmt.Errorf("unknown column %q", name)}
+		}
+		if seen[name] {
+			return nil, &LineError{Line: d.line, Err: fmt.Errorf("duplicate column %q", name)}
+		}
+		seen[name] = true
+		d.header = append(d.header, fd)
+	}
+	return d, nil
+}
+
+// next reads the next message into msg, returning io.EOF at the end.
+// Malformed rows are returned as *LineError and reading may go on.
+func (d *decoder) next(msg proto.Message) error {
+	if d.format == NDJSON {
+		return d.nextLine(msg)
+	}
+	return d.nextRecord(msg)
+}
+
+// nextLine decodes the next non blank NDJSON line.
+func (d *decoder) nextLine(msg proto.Message) error {
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return err
+		}
+		d.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		err = protojson.Unmarshal(line, msg)
+		if err != nil {
+			return &LineError{Line: d.line, Err: err}
+		}
+		return nil
+	}
+}
+
+// readLine returns the next line without its newline. Lines over
+// maxLineSize are skipped and returned as *LineError.
+func (d *decoder) readLine() ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, isPrefix, err := d.lines.ReadLine()
+		if err == io.EOF && (line != nil || tooLong) {
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+			tooLong = len(line) > maxLineSize
+		}
+		if !isPrefix {
+			break
+		}
+	}
+	if tooLong {
+		d.line++
+		return nil, &LineError{
+			Line: d.line,
+			Err:  fmt.Errorf("longer than %d bytes", maxLineSize),
+		}
+	}
+	return line, nil
+}
+
+// nextRecord decodes the next CSV record.
+func (d *decoder) nextRecord(msg proto.Message) error {
+	record, err := d.csv.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &LineError{Line: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return err
+	}
+	d.line, _ = d.csv.FieldPos(0)
+	if len(record) != len(d.header) {
+		return &LineError{
+			Line: d.line,
+			Err:  fmt.Errorf("%d cells, expected %d", len(record), len(d.header)),
+		}
+	}
+
+	m := msg.ProtoReflect()
+	for i, cell := range record {
+		if cell == "" {
+			continue
+		}
+		fd := d.header[i]
+		err = setCell(m, fd, cell)
+		if err != nil {
+			return &LineError{Line: d.line, Err: fmt.Errorf("%s: %w", fd.Name(), err)}
+		}
+	}
+	return nil
+}
+
+// csvKind reports whether fd can be a CSV column.
+func csvKind(fd protoreflect.FieldDescriptor) bool {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return true
+	case protoreflect.MessageKind:
+		return fd.IsList()
+	case protoreflect.Int32Kind, protoreflect.Int64Kind:
+		return !fd.IsList()
+	}
+	return false
+}
+
+// setCell parses cell into field fd of m.
+func setCell(m protoreflect.Message, fd protoreflect.FieldDescriptor, cell string) error {
+	if fd.IsList() && fd.Kind() == protoreflect.MessageKind {
+		var values []json.RawMessage
+		err := json.Unmarshal([]byte(cell), &values)
+		if err != nil {
+			return errors.New("expected a JSON array of objects")
+		}
+		list := m.Mutable(fd).List()
+		for i, value := range values {
+			elem := list.NewElement()
+			err = protojson.Unmarshal(value, elem.Message().Interface())
+			if err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+			list.Append(elem)
+		}
+		return nil
+	}
+	if fd.IsList() {
+		var values []string
+		err := json.Unmarshal([]byte(cell), &values)
+		if err != nil {
+			return errors.New("expected a JSON array of strings")
+		}
+		list := m.Mutable(fd).List()
+		for _, value := range values {
+			list.Append(protoreflect.ValueOfString(value))
+		}
+		return nil
+	}
+
+	switch fd.Kind() {
+	case protoreflect.Int32Kind:
+		n, err := strconv.ParseInt(cell, 10, 32)
+		if err != nil {
+			return errors.New("expected a 32 bit integer")
+		}
+		m.Set(fd, protoreflect.ValueOfInt32(int32(n)))
+	case protoreflect.Int64Kind:
+		n, err := strconv.ParseInt(cell, 10, 64)
+		if err != nil {
+			return errors.New("expected an integer")
+		}
+		m.Set(fd, protoreflect.ValueOfInt64(n))
+	default:
+		m.Set(fd, protoreflect.ValueOfString(cell))
+	}
+	return nil
+}
+
+// encoder writes one message per CSV record or NDJSON line.
+type encoder struct {
+	format Format
+	w      *bufio.Writer
+	csv    *csv.Writer
+	fields []protoreflect.FieldDescriptor
+	record []string
+	json   protojson.MarshalOptions
+}
+
+// newEncoder writes to w, and for CSV a header row of every field of desc.
+func newEncoder(w io.Writer, format Format, desc protoreflect.MessageDescriptor) (*encoder, error) {
+	e := &encoder{
+		format: format,
+		w:      bufio.NewWriter(w),
+		json:   protojson.MarshalOptions{UseProtoNames: true},
+	}
+	if format == NDJSON {
+		return e, nil
+	}
+
+	e.csv = csv.NewWriter(e.w)
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		e.fields = append(e.fields, fields.Get(i))
+		e.record = append(e.record, string(fields.Get(i).Name()))
+	}
+	err := e.csv.Write(e.record)
+	if err != nil {
+		return nil, fmt.Errorf("csv header %w", err)
+	}
+	return e, nil
+}
+
+// encode writes msg.
+func (e *encoder) encode(msg proto.Message) error {
+	if e.format == NDJSON {
+		line, err := e.json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("protojson.Marshal %w", err)
+		}
+		e.w.Write(line)
+		return e.w.WriteByte('\n')
+	}
+
+	m := msg.ProtoReflect()
+	for i, fd := range e.fields {
+		cell, err := formatCell(m, fd, e.json)
+		if err != nil {
+			return err
+		}
+		e.record[i] = cell
+	}
+	return e.csv.Write(e.record)
+}
+
+// flush writes buffered output.
+func (e *encoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		err := e.csv.Error()
+		if err != nil {
+			return err
+		}
+	}
+	return e.w.Flush()
+}
+
+// formatCell formats field fd of m, empty when unset. Messages are
+// formatted with opts.
+func formatCell(
+	m protoreflect.Message,
+	fd protoreflect.FieldDescriptor,
+	opts protojson.MarshalOptions,
+) (string, error) {
+	if !m.Has(fd) {
+		return "", nil
+	}
+	value := m.Get(fd)
+	if fd.IsList() && fd.Kind() == protoreflect.MessageKind {
+		list := value.List()
+		values := make([]json.RawMessage, list.Len())
+		for i := range values {
+			b, err := opts.Marshal(list.Get(i).Message().Interface())
+			if err != nil {
+				return "", fmt.Errorf("protojson.Marshal %w", err)
+			}
+			values[i] = b
+		}
+		b, err := json.Marshal(values)
+		if err != nil {
+			return "", fmt.Errorf("json.Marshal %w", err)
+		}
+		return string(b), nil
+	}
+	if fd.IsList() {
+		list := value.List()
+		values := make([]string, list.Len())
+		for i := range values {
+			values[i] = list.Get(i).String()
+		}
+		b, err := json.Marshal(values)
+		if err != nil {
+			return "", fmt.Errorf("json.Marshal %w", err)
+		}
+		return string(b), nil
+	}
+	return value.String(), nil
+}
@@ -0,0 +1,75 @@
+package records
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"fx-sample-app/gateway/postgres"
+	pb "fx-sample-app/proto/fxsample"
+)
+
+// Entities maps API collection names to the tables they are audited as.
+var Entities = map[string]string{
+	"people":        "person",
+	"places":        "place",
+	"relationships": "relationship",
+}
+
+// EntityName returns the API collection name of a table, the table
+// name when it has none.
+func EntityName(table string) string {
+	for name, t := range Entities {
+		if t == table {
+			return name
+		}
+	}
+	return table
+}
+
+// ChangeToPB converts a feed change to its API form.
+func ChangeToPB(c postgres.Change) *pb.Change {
+	return &pb.Change{
+		Entity:    EntityName(c.Entity),
+		Id:        c.ID,
+		Operation: string(c.Operation),
+		Version:   c.Version,
+	}
+}
+
+// AuditEntryToPB converts an audit entry to its API form.
+func AuditEntryToPB(e postgres.AuditEntry) (*pb.AuditEntry, error) {
+	entry := &pb.AuditEntry{
+		Id:        e.ID,
+		Entity:    EntityName(e.Entity),
+		EntityId:  e.EntityID,
+		Operation: string(e.Operation),
+		Actor:     e.Actor,
+		At:        e.At,
+	}
+
+	var err error
+	entry.Before, err = documentToPB(e.Before)
+	if err != nil {
+		return nil, fmt.Errorf("before %w", err)
+	}
+	entry.After, err = documentToPB(e.After)
+	if err != nil {
+		return nil, fmt.Errorf("after %w", err)
+	}
+	return entry, nil
+}
+
+// documentToPB converts a JSON object, nil when empty.
+func documentToPB(doc postgres.RawJSON) (*structpb.Struct, error) {
+	if len(doc) == 0 {
+		return nil, nil
+	}
+	var fields map[string]interface{}
+	err := json.Unmarshal(doc, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("json.Unmarshal %w", err)
+	}
+	return structpb.NewStruct(fields)
+}
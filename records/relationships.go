@@ -0,0 +1,121 @@
+package records
+
+import (
+	"fmt"
+	"time"
+
+	"fx-sample-app/gateway/postgres"
+	pb "fx-sample-app/proto/fxsample"
+)
+
+// roles maps API roles to stored roles.
+var roles = map[pb.Role]postgres.Role{
+	pb.Role_ROLE_VISITOR:  postgres.RoleVisitor,
+	pb.Role_ROLE_RESIDENT: postgres.RoleResident,
+}
+
+// ParseDate parses a YYYY-MM-DD date.
+func ParseDate(field, s string) (time.Time, error) {
+	day, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s %q is not a YYYY-MM-DD date", field, s)
+	}
+	return day, nil
+}
+
+// RoleFromPB converts an API role, empty when unspecified.
+func RoleFromPB(role pb.Role) postgres.Role {
+	return roles[role]
+}
+
+// ValidateRelationship checks r names a person, a place, a role and a
+// date range.
+func ValidateRelationship(r *pb.Relationship) error {
+	err := ValidateID(r.Id)
+	if err != nil {
+		return err
+	}
+	if r.PersonId == "" || r.PlaceId == "" {
+		return fmt.Errorf("person_id and place_id are required")
+	}
+	err = ValidateID(r.PersonId)
+	if err != nil {
+		return err
+	}
+	err = ValidateID(r.PlaceId)
+	if err != nil {
+		return err
+	}
+	if _, ok := roles[r.Role]; !ok {
+		return fmt.Errorf("role is required")
+	}
+
+	start, err := ParseDate("start_date", r.StartDate)
+	if err != nil {
+		return err
+	}
+	if r.EndDate != "" {
+		end, err := ParseDate("end_date", r.EndDate)
+		if err != nil {
+			return err
+		}
+		if end.Before(start) {
+			return fmt.Errorf("end_date is before start_date")
+		}
+	}
+	if r.Timestamp < 0 {
+		return fmt.Errorf("timestamp must not be negative")
+	}
+	return nil
+}
+
+// RelationshipFromPB converts a validated API relationship to a row.
+func RelationshipFromPB(r *pb.Relationship) (postgres.Relationship, error) {
+	row := postgres.Relationship{
+		ID:        r.Id,
+		PersonID:  r.PersonId,
+		PlaceID:   r.PlaceId,
+		Role:      RoleFromPB(r.Role),
+		Timestamp: r.Timestamp,
+	}
+
+	var err error
+	row.StartDate, err = ParseDate("start_date", r.StartDate)
+	if err != nil {
+		return postgres.Relationship{}, err
+	}
+	if r.EndDate != "" {
+		row.EndDate.Time, err = ParseDate("end_date", r.EndDate)
+		if err != nil {
+			return postgres.Relationship{}, err
+		}
+		row.EndDate.Valid = true
+	}
+	return row, nil
+}
+
+// RelationshipToPB converts a row to an API relationship.
+func RelationshipToPB(r postgres.Relationship) *pb.Relationship {
+	rel := &pb.Relationship{
+		Id:        r.ID,
+		PersonId:  r.PersonID,
+		PlaceId:   r.PlaceID,
+		StartDate: r.StartDate.Format(time.DateOnly),
+		Timestamp: r.Timestamp,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+		Version:   r.Version,
+	}
+	if r.DeletedAt.Valid {
+		rel.DeletedAt = &r.DeletedAt.Int64
+	}
+	for role, stored := range roles {
+		if stored == r.Role {
+			rel.Role = role
+		}
+	}
+	if r.EndDate.Valid {
+		rel.EndDate = r.EndDate.Time.Format(time.DateOnly)
+	}
+	return rel
+}
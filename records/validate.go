@@ -0,0 +1,172 @@
+// Package records validates, converts and bulk loads people and places.
+package records
+
+import (
+	"fmt"
+	"net/mail"
+
+	"github.com/google/uuid"
+
+	pb "fx-sample-app/proto/fxsample"
+)
+
+const (
+	// maxFieldLength bounds free text fields.
+	maxFieldLength = 255
+	// MaxComments bounds comments per place.
+	MaxComments = 100
+	// maxCommentLength bounds comment text.
+	maxCommentLength = 1024
+	// maxCommentFields bounds the fields of a comment.
+	maxCommentFields = 20
+)
+
+var (
+	// PersonFields are the person fields an update may set.
+	PersonFields = []string{"first_name", "last_name", "email", "timestamp"}
+	// PlaceFields are the place fields an update may set. Comments have
+	// their own methods.
+	PlaceFields = []string{"country", "city", "telcode", "timestamp"}
+	// NewPlaceFields are the place fields set on create and import.
+	NewPlaceFields = append(PlaceFields[:len(PlaceFields):len(PlaceFields)], "comments")
+)
+
+// ValidateID checks a client supplied id is a UUID.
+func ValidateID(id string) error {
+	if id == "" {
+		return nil
+	}
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("id %q is not a UUID", id)
+	}
+	return nil
+}
+
+// ValidatePerson checks the given fields of p.
+func ValidatePerson(p *pb.Person, fields []string) error {
+	set := fieldSet(fields)
+	if set["first_name"] {
+		err := validateText("first_name", p.FirstName, true)
+		if err != nil {
+			return err
+		}
+	}
+	if set["last_name"] {
+		err := validateText("last_name", p.LastName, false)
+		if err != nil {
+			return err
+		}
+	}
+	if set["email"] && p.Email != "" {
+		err := validateText("email", p.Email, false)
+		if err != nil {
+			return err
+		}
+		addr, err := mail.ParseAddress(p.Email)
+		if err != nil || addr.Address != p.Email {
+			return fmt.Errorf("email %q is not a valid address", p.Email)
+		}
+	}
+	if set["timestamp"] && p.Timestamp < 0 {
+		return fmt.Errorf("timestamp must not be negative")
+	}
+	return nil
+}
+
+// ValidatePlace checks the given fields of p.
+func ValidatePlace(p *pb.Place, fields []string) error {
+	set := fieldSet(fields)
+	if set["country"] {
+		err := validateText("country", p.Country, true)
+		if err != nil {
+			return err
+		}
+	}
+	if set["city"] {
+		err := validateText("city", p.GetCity(), false)
+		if err != nil {
+			return err
+		}
+	}
+	if set["comments"] {
+		if len(p.Comments) > MaxComments {
+			return fmt.Errorf("more than %d comments", MaxComments)
+		}
+		ids := make(map[string]bool, len(p.Comments))
+		for i, c := range p.Comments {
+			err := ValidateComment(c)
+			if err != nil {
+				return fmt.Errorf("comments[%d]: %w", i, err)
+			}
+			if c.Id != "" && ids[c.Id] {
+				return fmt.Errorf("comments[%d]: duplicate id %q", i, c.Id)
+			}
+			ids[c.Id] = true
+		}
+	}
+	if set["telcode"] && (p.Telcode < 1 || p.Telcode > 999) {
+		return fmt.Errorf("telcode must be between 1 and 999")
+	}
+	if set["timestamp"] && p.Timestamp < 0 {
+		return fmt.Errorf("timestamp must not be negative")
+	}
+	return nil
+}
+
+// ValidateComment checks a comment has an author and text or fields
+// within bounds.
+func ValidateComment(c *pb.Comment) error {
+	if c == nil {
+		return fmt.Errorf("comment is required")
+	}
+	err := ValidateID(c.Id)
+	if err != nil {
+		return err
+	}
+	err = validateText("author", c.Author, true)
+	if err != nil {
+		return err
+	}
+	if c.Text == "" && len(c.Fields) == 0 {
+		return fmt.Errorf("text or fields is required")
+	}
+	if len(c.Text) > maxCommentLength {
+		return fmt.Errorf("text longer than %d characters", maxCommentLength)
+	}
+	if len(c.Fields) > maxCommentFields {
+		return fmt.Errorf("more than %d fields", maxCommentFields)
+	}
+	for key, value := range c.Fields {
+		if key == "" || len(key) > maxFieldLength {
+			return fmt.Errorf("field names must be 1 to %d characters", maxFieldLength)
+		}
+		if len(value) > maxFieldLength {
+			return fmt.Errorf("field %s longer than %d characters", key, maxFieldLength)
+		}
+	}
+	if c.CreatedAt < 0 {
+		return fmt.Errorf("created_at must not be negative")
+	}
+	return nil
+}
+
+// validateText checks a free text field's length, and presence when required.
+func validateText(field, value string, required bool) error {
+	if required && value == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+	if len(value) > maxFieldLength {
+		return fmt.Errorf("%s longer than %d characters", field, maxFieldLength)
+	}
+	return nil
+}
+
+// fieldSet returns fields as a set.
+func fieldSet(fields []string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		set[field] = true
+	}
+	return set
+}